@@ -18,7 +18,8 @@ import (
 	"google.golang.org/protobuf/proto"
 )
 
-func DeployProgramme(code []byte, mainAccountIndex int, config pb.UserConfig) string {
+// DeployProgramme deploys the program and waits for the TEE to process it, a failed deployment is fatal
+func DeployProgramme(code []byte, mainAccountIndex int, config *pb.UserConfig) string {
 	client := help.Client
 	account := help.Accounts[mainAccountIndex]
 	parsedABI := help.ParsedClientABI
@@ -30,7 +31,7 @@ func DeployProgramme(code []byte, mainAccountIndex int, config pb.UserConfig) st
 		log.Fatalf("Failed to encrypt code: %v", err)
 	}
 	// encode config
-	configBytes, err := proto.Marshal(&config)
+	configBytes, err := proto.Marshal(config)
 	if err != nil {
 		log.Fatalf("Failed to marshal config: %v", err)
 	}
@@ -38,10 +39,19 @@ func DeployProgramme(code []byte, mainAccountIndex int, config pb.UserConfig) st
 	if err != nil {
 		log.Fatalf("Failed to encrypt config: %v", err)
 	}
+	// the TEE reports a failed deployment with the result key
+	resultKey, err := key.GenerateAESKey()
+	if err != nil {
+		log.Fatalf("Failed to generate result key: %v", err)
+	}
+	encryptedResultKey, err := key.ECIESEncrypt([]byte(resultKey))
+	if err != nil {
+		log.Fatalf("Failed to encrypt result key: %v", err)
+	}
 	transactionKey := key.TXPubKeyBytes
 
 	// encode the constructor arguments
-	constructorArgs, err := parsedABI.Pack("", encryptedCode, encryptedConfig, transactionKey, common.HexToAddress(help.MCAddress))
+	constructorArgs, err := parsedABI.Pack("", encryptedCode, encryptedConfig, encryptedResultKey, transactionKey, common.HexToAddress(help.MCAddress))
 	if err != nil {
 		log.Fatalf("Failed to pack constructor arguments: %v", err)
 	}
@@ -72,6 +82,10 @@ func DeployProgramme(code []byte, mainAccountIndex int, config pb.UserConfig) st
 	}
 
 	// send transaction
+	fromBlock, err := client.BlockNumber(context.Background())
+	if err != nil {
+		log.Fatalf("Failed to get block number: %v", err)
+	}
 	err = client.SendTransaction(context.Background(), signedTx)
 	if err != nil {
 		log.Fatalf("Failed to send transaction: %v", err)
@@ -79,7 +93,12 @@ func DeployProgramme(code []byte, mainAccountIndex int, config pb.UserConfig) st
 
 	// get contract address
 	contractAddress := crypto.CreateAddress(common.HexToAddress(account.Address), nonce)
-	fmt.Printf("Contract deployed at address: %s\n", contractAddress.Hex())
+	fmt.Printf("Contract deployed at address: %s, waiting for the TEE\n", contractAddress.Hex())
+	err = WaitForDeployment(contractAddress, fromBlock, resultKey)
+	if err != nil {
+		log.Fatalf("Failed to deploy program: %v", err)
+	}
+	fmt.Printf("Program deployed at address: %s\n", contractAddress.Hex())
 	// save contract address to file
 	// saveContractAddressToFile("./artifacts/programAddress.json", contractAddress)
 	return contractAddress.Hex()
@@ -0,0 +1,99 @@
+package deploy
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/big"
+	"time"
+
+	"client/help"
+	"client/key"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// time to wait for the TEE to process a deployment
+var WaitTimeout = 5 * time.Minute
+
+const pollInterval = time.Second
+
+// WaitForDeployment waits until the TEE has stored the code of the program or reported an error.
+// The error is encrypted with the result key sent in the Deploy event.
+func WaitForDeployment(contractAddress common.Address, fromBlock uint64, resultKey string) error {
+	deadline := time.Now().Add(WaitTimeout)
+	for time.Now().Before(deadline) {
+		deployed, err := isDeployed(contractAddress)
+		if err != nil {
+			log.Printf("Failed to check deployment: %v", err)
+		} else if deployed {
+			return nil
+		}
+
+		msg, found, err := deploymentError(contractAddress, fromBlock, resultKey)
+		if err != nil {
+			log.Printf("Failed to check deployment errors: %v", err)
+		} else if found {
+			return fmt.Errorf("deployment of %v failed: %s", contractAddress.Hex(), msg)
+		}
+		time.Sleep(pollInterval)
+	}
+	return fmt.Errorf("deployment of %v not processed after %v", contractAddress.Hex(), WaitTimeout)
+}
+
+// the code hash is only set by a successful deployment
+func isDeployed(contractAddress common.Address) (bool, error) {
+	parsedABI := help.ParsedMCABI
+	MCAddress := common.HexToAddress(help.MCAddress)
+	callData, err := parsedABI.Pack("ProgramCodes", contractAddress)
+	if err != nil {
+		return false, fmt.Errorf("failed to pack ProgramCodes call data: %v", err)
+	}
+	result, err := help.Client.CallContract(context.Background(), ethereum.CallMsg{
+		To:   &MCAddress,
+		Data: callData,
+	}, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to call ProgramCodes: %v", err)
+	}
+	var codeHash [32]byte
+	err = parsedABI.UnpackIntoInterface(&codeHash, "ProgramCodes", result)
+	if err != nil {
+		return false, fmt.Errorf("failed to unpack ProgramCodes result: %v", err)
+	}
+	return codeHash != [32]byte{}, nil
+}
+
+// look for the Result event the TEE emits for a failed deployment
+func deploymentError(contractAddress common.Address, fromBlock uint64, resultKey string) (string, bool, error) {
+	parsedABI := help.ParsedClientABI
+	event := parsedABI.Events["Result"]
+	query := ethereum.FilterQuery{
+		FromBlock: new(big.Int).SetUint64(fromBlock),
+		Addresses: []common.Address{contractAddress},
+		Topics:    [][]common.Hash{{event.ID}},
+	}
+	logs, err := help.Client.FilterLogs(context.Background(), query)
+	if err != nil {
+		return "", false, fmt.Errorf("failed to filter logs: %v", err)
+	}
+	for _, vLog := range logs {
+		var result struct {
+			EncryptedResult    []byte
+			EncryptedResultKey []byte
+		}
+		err := parsedABI.UnpackIntoInterface(&result, "Result", vLog.Data)
+		if err != nil {
+			log.Printf("Failed to unpack log data: %v", err)
+			continue
+		}
+		msg, err := key.DecryptAES(result.EncryptedResult, resultKey)
+		if err != nil {
+			// the TEE could not decrypt the result key, the message is not encrypted
+			return string(result.EncryptedResult), true, nil
+		}
+		return string(msg), true, nil
+	}
+	return "", false, nil
+}
@@ -5,6 +5,8 @@ go 1.21
 require (
 	github.com/ethereum/go-ethereum v1.14.2
 	google.golang.org/protobuf v1.36.4
+	racetee/config v0.0.0
+	tee v0.0.0
 )
 
 require (
@@ -31,3 +33,8 @@ require (
 	golang.org/x/sys v0.22.0 // indirect
 	rsc.io/tmplfunc v0.0.3 // indirect
 )
+
+replace racetee/config => ../config
+
+// declarations of the host packages of golang programs, the TEE provides them
+replace tee => ./userpackage/tee
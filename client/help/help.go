@@ -1,12 +1,18 @@
 package help
 
 import (
+	"archive/zip"
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
+	"io/fs"
 	"io/ioutil"
 	"log"
 	"math/big"
+	"os"
+	"path/filepath"
+	"racetee/config"
 	"strings"
 
 	"github.com/ethereum/go-ethereum/accounts/abi"
@@ -41,15 +47,33 @@ var (
 	ChainID         *big.Int
 )
 
-func init() {
+// Init connects to the node and loads the contracts and accounts of the configuration
+func Init(conf config.Config) {
+	RPCURL = conf.RPCURL
+	AccountsPath = conf.AccountsPath
+	MCAddressPath = conf.MCAddressPath
+	ClientABIPath = conf.Artifact("ProgramContract")
+	MCABIPath = conf.Artifact("ManagementContract")
+
 	getClient()
-	MCAddress = loadAddress(MCAddressPath)
+	MCAddress = conf.MCAddress
+	if MCAddress == "" {
+		MCAddress = loadAddress(MCAddressPath)
+	}
 	ParsedClientABI = LoadABI(ClientABIPath)
 	ParsedMCABI = LoadABI(MCABIPath)
 	Accounts = LoadAccounts()
 
-	// ChainID = big.NewInt(1337) // for ganache
-	ChainID = big.NewInt(31337) // for hardhat
+	// detect the chain ID from the node when not configured (31337 for hardhat, 1337 for ganache)
+	if conf.ChainID != 0 {
+		ChainID = new(big.Int).SetUint64(conf.ChainID)
+	} else {
+		chainID, err := Client.NetworkID(context.Background())
+		if err != nil {
+			log.Fatalf("Failed to get chain ID: %v", err)
+		}
+		ChainID = chainID
+	}
 }
 
 func getClient() *ethclient.Client {
@@ -171,10 +195,68 @@ func loadAddress(filePath string) string {
 	return result.Address
 }
 
+// LoadGolangCode reads a golang program, either a single .go file or a directory packaged by PackGolangDir
 func LoadGolangCode(programmePath string) []byte {
+	info, err := os.Stat(programmePath)
+	if err != nil {
+		log.Fatalf("Failed to read file: %v", err)
+	}
+	if info.IsDir() {
+		data, err := PackGolangDir(programmePath)
+		if err != nil {
+			log.Fatalf("Failed to pack directory: %v", err)
+		}
+		return data
+	}
 	data, err := ioutil.ReadFile(programmePath)
 	if err != nil {
 		log.Fatalf("Failed to read file: %v", err)
 	}
 	return data
 }
+
+// PackGolangDir packages a golang program directory as a zip archive.
+// The Go files of the directory form the program, test files and hidden files are left out,
+// the other files are data files the program reads with the tee/files package.
+// The archive only depends on the files, so the same directory always gives the same code.
+func PackGolangDir(dir string) ([]byte, error) {
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	// WalkDir visits the files in lexical order
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path != dir && strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() || strings.HasSuffix(d.Name(), "_test.go") {
+			return nil
+		}
+		name, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		// no modification time, so that the archive does not change when the files do not
+		f, err := w.CreateHeader(&zip.FileHeader{Name: filepath.ToSlash(name), Method: zip.Deflate})
+		if err != nil {
+			return err
+		}
+		_, err = f.Write(data)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -24,6 +24,10 @@ var cacheResultKey map[string]string
 
 func init() {
 	cacheResultKey = make(map[string]string)
+}
+
+// Init fetches the transaction key from the management contract, must be called after help.Init
+func Init() {
 	var err error
 	TXPubKey, TXPubKeyBytes, err = GetTXPubKey()
 	if err != nil {
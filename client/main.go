@@ -3,14 +3,17 @@ package main
 import (
 	"client/deploy"
 	"client/help"
+	"client/key"
 	"client/operation"
 	pb "client/proto"
 	"encoding/json"
 	"flag"
 	"fmt"
+	"log"
 	"math/big"
 	"math/rand"
 	"os"
+	"racetee/config"
 	"strings"
 	"time"
 
@@ -18,23 +21,33 @@ import (
 	"google.golang.org/protobuf/proto"
 )
 
-const golangProgPath = "./userpackage/goTest/goTest.go"
+const golangProgPath = "./userpackage/goTest"
 const solidityProgPath = "./artifacts/UserContract.json"
 const solidityProg2Path = "./artifacts/UserContract2.json"
 const ERC20Path = "./artifacts/ERC20.json"
 const DEXPath = "./artifacts/DEX.json"
 const quickSelectPath = "./artifacts/QuickSelect.json"
 const SPAPath = "./artifacts/SecondPriceAuction.json"
-const kMeanProgPath = "./userpackage/KMean/KMean.go"
+const kMeanProgPath = "./userpackage/KMean"
 const calProgPath = "./artifacts/Calculate.json"
 
 var mainAccountIndex = 7
 
-var defaultConfig = pb.UserConfig{
+var defaultConfig = &pb.UserConfig{
 	HistoryKeyDiscard: true,
 	KeyRotation:       200,
 	ACL:               []string{}, // empty ACL means anyone can execute the program
+	VM:                pb.VMType_Solidity,
 }
+
+// config of the golang programs
+var golangConfig = &pb.UserConfig{
+	HistoryKeyDiscard: true,
+	KeyRotation:       200,
+	ACL:               []string{},
+	VM:                pb.VMType_Golang,
+}
+
 var timeInterval int
 var blockInterval = 1
 
@@ -48,14 +61,26 @@ func main() {
 	var adr3 string
 	var i int
 	var userIndex int
-	flag.StringVar(&mode, "mode", "c", "User mode: e(executor), d(deployer) or c(combined)")
+	var aclOp string
+	var aclAddrs string
+	flag.StringVar(&mode, "mode", "c", "User mode: e(executor), d(deployer), c(combined) or acl(change ACL of adr1)")
 	flag.StringVar(&userCase, "case", "s", "Use cases: g(golang test), kmean(KMean), s(solidity test), i(interact test), erc20(erc20), dex(dex), quick(quick select), SPA(second price auction), or cal(calculation)")
 	flag.StringVar(&adr1, "adr1", "", "Address of the first program")
 	flag.StringVar(&adr2, "adr2", "", "Address of the second program")
 	flag.StringVar(&adr3, "adr3", "", "Address of the third program")
 	flag.IntVar(&i, "i", 2000000, "Execution interval(in microseconds)")
 	flag.IntVar(&userIndex, "userIndex", 0, "User index")
+	flag.StringVar(&aclOp, "aclOp", "add", "ACL operation: add, remove or replace")
+	flag.StringVar(&aclAddrs, "aclAddrs", "", "Comma separated addresses for the ACL operation")
+	flag.DurationVar(&deploy.WaitTimeout, "deployTimeout", deploy.WaitTimeout, "Time to wait for the TEE to process a deployment")
+	config.RegisterFlags(flag.CommandLine)
 	flag.Parse()
+	conf, err := config.Load(flag.CommandLine)
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+	help.Init(conf)
+	key.Init()
 
 	timeInterval = i
 	mainAccountIndex += userIndex
@@ -110,6 +135,8 @@ func main() {
 		case "cal":
 			adr1 = deployCal().Hex()
 		}
+	case "acl":
+		changeACL(common.HexToAddress(adr1), aclOp, aclAddrs)
 	case "c":
 		switch userCase {
 		case "g":
@@ -143,9 +170,31 @@ func main() {
 	}
 }
 
+func changeACL(PRGAddress common.Address, op string, addrs string) {
+	var aclOp pb.ACLOperation
+	switch op {
+	case "add":
+		aclOp = pb.ACLOperation_Add
+	case "remove":
+		aclOp = pb.ACLOperation_Remove
+	case "replace":
+		aclOp = pb.ACLOperation_Replace
+	default:
+		fmt.Printf("Unknown ACL operation: %s\n", op)
+		return
+	}
+	addresses := []string{}
+	for _, addr := range strings.Split(addrs, ",") {
+		if addr = strings.TrimSpace(addr); addr != "" {
+			addresses = append(addresses, addr)
+		}
+	}
+	operation.ChangeACL(PRGAddress, mainAccountIndex, aclOp, addresses)
+}
+
 func deployGolang() common.Address {
 	code := help.LoadGolangCode(golangProgPath)
-	PRGAddress := deploy.DeployProgramme([]byte(code), mainAccountIndex, defaultConfig)
+	PRGAddress := deploy.DeployProgramme([]byte(code), mainAccountIndex, golangConfig)
 	return common.HexToAddress(PRGAddress)
 }
 
@@ -159,8 +208,11 @@ func runGolang(PRGAddress common.Address) {
 	for {
 		select {
 		case <-ticker.C:
-			input := `{"funcName": "Add", "args": 1}`
-			operation.Execute(PRGAddress, mainAccountIndex, []byte(input))
+			input, err := proto.Marshal(&pb.GolangInput{FuncName: "Add", Args: []byte(`[1]`)})
+			if err != nil {
+				panic(err)
+			}
+			operation.Execute(PRGAddress, mainAccountIndex, input)
 		}
 	}
 }
@@ -195,9 +247,6 @@ func deployInteract() (common.Address, common.Address) {
 	code := help.LoadBytecode(solidityProgPath)
 	PRGAddress := deploy.DeployProgramme(code, mainAccountIndex, defaultConfig)
 
-	// wait for the block to be mined
-	time.Sleep(time.Duration(blockInterval) * time.Second)
-
 	code2 := help.LoadBytecode(solidityProg2Path)
 	// generate constructor arguments
 	prog2ABI := help.LoadABI(solidityProg2Path)
@@ -207,9 +256,6 @@ func deployInteract() (common.Address, common.Address) {
 	}
 	PRGAddress2 := deploy.DeployProgramme(append(code2, prog2ConstructorInput...), mainAccountIndex, defaultConfig)
 
-	// wait for the block to be mined
-	time.Sleep(time.Duration(blockInterval) * time.Second)
-
 	return common.HexToAddress(PRGAddress), common.HexToAddress(PRGAddress2)
 }
 
@@ -258,8 +304,6 @@ func _deployERC20(name string, symbol string) common.Address {
 		fmt.Printf("Failed to pack ERC20 constructor function call: %v", err)
 	}
 	address := deploy.DeployProgramme(append(code, progConstructorInput...), mainAccountIndex, defaultConfig)
-	// wait for the block to be mined
-	time.Sleep(time.Duration(blockInterval) * time.Second)
 	return common.HexToAddress(address)
 }
 
@@ -304,9 +348,6 @@ func deployDEX() (common.Address, []common.Address) {
 	}
 	address := deploy.DeployProgramme(append(code, progConstructorInput...), mainAccountIndex, defaultConfig)
 
-	// wait for the block to be mined
-	time.Sleep(time.Duration(blockInterval) * time.Second)
-
 	return common.HexToAddress(address), []common.Address{TokenA, TokenB}
 
 }
@@ -373,9 +414,6 @@ func deployQuickSelect() common.Address {
 	code := help.LoadBytecode(quickSelectPath)
 	address := deploy.DeployProgramme(code, mainAccountIndex, defaultConfig)
 
-	// wait for the block to be mined
-	time.Sleep(time.Duration(blockInterval) * time.Second)
-
 	return common.HexToAddress(address)
 }
 
@@ -434,9 +472,6 @@ func deploySPA() (common.Address, common.Address) {
 	}
 	deployedAddr := deploy.DeployProgramme(append(code, constructorInput...), mainAccountIndex, defaultConfig)
 
-	// wait for the block to be mined
-	time.Sleep(time.Duration(blockInterval) * time.Second)
-
 	return common.HexToAddress(deployedAddr), auctionToken
 }
 
@@ -476,36 +511,11 @@ func runSPA(prgAddress common.Address, tokenAddr common.Address) {
 
 func deployKMean() common.Address {
 	code := help.LoadGolangCode(kMeanProgPath)
-	PRGAddress := deploy.DeployProgramme([]byte(code), mainAccountIndex, defaultConfig)
-
-	// wait for the block to be mined
-	time.Sleep(time.Duration(blockInterval) * time.Second)
+	PRGAddress := deploy.DeployProgramme([]byte(code), mainAccountIndex, golangConfig)
 
 	return common.HexToAddress(PRGAddress)
 }
 
-// generatePointsString converts a slice of float64 slices (points)
-// into an expression string like: "[]Point{{10.0, 20.0, 30.0}, {...}, ...}".
-func generatePointsString(points [][]float64) string {
-	var sb strings.Builder
-	sb.WriteString("[]Point{")
-	for i, pt := range points {
-		sb.WriteString("{")
-		for j, v := range pt {
-			sb.WriteString(fmt.Sprintf("%v", v))
-			if j < len(pt)-1 {
-				sb.WriteString(", ")
-			}
-		}
-		sb.WriteString("}")
-		if i < len(points)-1 {
-			sb.WriteString(", ")
-		}
-	}
-	sb.WriteString("}")
-	return sb.String()
-}
-
 func runKMean(PRGAddress common.Address) {
 	go operation.Result(PRGAddress)
 
@@ -523,18 +533,19 @@ func runKMean(PRGAddress common.Address) {
 		data[i] = point
 	}
 
-	// Dynamically generate the data expression.
-	dataStr := generatePointsString(data)
 	// Number of clusters.
 	k := 10
 	// Maximum iterations.
 	maxIter := 100
 
-	// Construct the args expression without extra quotes.
-	argsExpr := fmt.Sprintf("%s, %d, %d", dataStr, k, maxIter)
+	// Encode the arguments as a JSON array, decoded into the parameter types by the TEE.
+	args, err := json.Marshal([]interface{}{data, k, maxIter})
+	if err != nil {
+		panic(err)
+	}
 	_input := pb.GolangInput{
 		FuncName: "KMeans",
-		Args:     []byte(argsExpr),
+		Args:     args,
 	}
 	input, err := proto.Marshal(&_input)
 	if err != nil {
@@ -561,9 +572,6 @@ func deployCal() common.Address {
 	code := help.LoadBytecode(calProgPath)
 	PRGAddress := deploy.DeployProgramme([]byte(code), mainAccountIndex, defaultConfig)
 
-	// wait for the block to be mined
-	time.Sleep(time.Duration(blockInterval) * time.Second)
-
 	return common.HexToAddress(PRGAddress)
 }
 
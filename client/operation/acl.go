@@ -0,0 +1,47 @@
+package operation
+
+import (
+	"log"
+
+	"client/help"
+	"client/key"
+	pb "client/proto"
+
+	"github.com/ethereum/go-ethereum/common"
+	"google.golang.org/protobuf/proto"
+)
+
+// ChangeACL asks the TEE to add, remove or replace the addresses allowed to execute the program.
+// Only the deployer of the program is allowed to change the ACL.
+func ChangeACL(contractAddress common.Address, accountNum int, op pb.ACLOperation, addresses []string) {
+	parsedABI := help.ParsedClientABI
+
+	// encode and encrypt the ACL input
+	inputBytes, err := proto.Marshal(&pb.ACLInput{Op: op, Addresses: addresses})
+	if err != nil {
+		log.Fatalf("Failed to marshal ACL input: %v", err)
+	}
+	encryptedInput, err := key.ECIESEncrypt(inputBytes)
+	if err != nil {
+		log.Fatalf("Failed to encrypt ACL input: %v", err)
+	}
+	// a rejected change is reported to the caller with the result key
+	resultKey, err := key.GenerateAESKey()
+	if err != nil {
+		log.Fatalf("Failed to generate result key: %v", err)
+	}
+	encryptedResultKey, err := key.ECIESEncrypt([]byte(resultKey))
+	if err != nil {
+		log.Fatalf("Failed to encrypt result key: %v", err)
+	}
+	transactionKey := key.TXPubKeyBytes
+	data, err := parsedABI.Pack("changeACL", encryptedInput, encryptedResultKey, transactionKey)
+	if err != nil {
+		log.Fatalf("Failed to pack changeACL call data: %v", err)
+	}
+
+	BaseExeuction(contractAddress, accountNum, data)
+
+	// cache the result key
+	key.SaveResultKey(string(encryptedResultKey), resultKey)
+}
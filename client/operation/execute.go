@@ -8,10 +8,12 @@ import (
 
 	"client/help"
 	"client/key"
+	pb "client/proto"
 
 	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
+	"google.golang.org/protobuf/proto"
 )
 
 var nonce uint64 = 0
@@ -26,8 +28,18 @@ func initNonce(address common.Address) {
 	nonce = _nonce
 }
 
+// Execute runs a program with the gas limit of the program
 func Execute(contractAddress common.Address, accountNum int, input []byte) {
+	ExecuteWithGas(contractAddress, accountNum, input, 0)
+}
+
+// ExecuteWithGas runs a program with a gas limit in the EVM lower than the one of the program, 0 for the program limit
+func ExecuteWithGas(contractAddress common.Address, accountNum int, input []byte, gasLimit uint64) {
 	parsedABI := help.ParsedClientABI
+	envelope, err := proto.Marshal(&pb.ExecutionInput{Input: input, GasLimit: gasLimit})
+	if err != nil {
+		log.Fatalf("Failed to encode input: %v", err)
+	}
 	// encode the execution call
 	resultKey, err := key.GenerateAESKey()
 	if err != nil {
@@ -38,7 +50,7 @@ func Execute(contractAddress common.Address, accountNum int, input []byte) {
 		log.Fatalf("Failed to encrypt result key: %v", err)
 	}
 	transactionKey := key.TXPubKeyBytes
-	encryptedInput, err := key.ECIESEncrypt(input)
+	encryptedInput, err := key.ECIESEncrypt(envelope)
 	if err != nil {
 		log.Fatalf("Failed to encrypt input: %v", err)
 	}
@@ -2,15 +2,18 @@ package operation
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"log"
 
 	"client/help"
 	"client/key"
+	pb "client/proto"
 
 	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
+	"google.golang.org/protobuf/proto"
 )
 
 func Result(contractAddr common.Address) {
@@ -61,7 +64,28 @@ func Result(contractAddr common.Address) {
 				if err != nil {
 					fmt.Printf("Failed to decrypt result: %v", err)
 				}
-				fmt.Printf("Result Event (%s): Result = %v\n", contractAddr.Hex(), decryptedResult)
+				var envelope pb.ExecutionResult
+				err = proto.Unmarshal(decryptedResult, &envelope)
+				if err != nil {
+					fmt.Printf("Failed to decode result: %v", err)
+				}
+				if envelope.Error != "" && len(envelope.Revert) > 0 {
+					// a custom error has no reason, its raw data is decoded with the ABI of the program
+					fmt.Printf("Result Event (%s): Error = %s, Revert = %q, RevertData = 0x%x, GasUsed = %d\n", contractAddr.Hex(), envelope.Error, envelope.RevertReason, envelope.Revert, envelope.GasUsed)
+					continue
+				}
+				if envelope.Error != "" {
+					fmt.Printf("Result Event (%s): Error = %s, GasUsed = %d\n", contractAddr.Hex(), envelope.Error, envelope.GasUsed)
+					continue
+				}
+				// the result is JSON, the bytes returned by a solidity or wasm program are a base64 string
+				var value interface{}
+				err = json.Unmarshal(envelope.Result, &value)
+				if err != nil {
+					fmt.Printf("Failed to decode result: %v", err)
+					continue
+				}
+				fmt.Printf("Result Event (%s): Result = %v, GasUsed = %d\n", contractAddr.Hex(), value, envelope.GasUsed)
 			}
 		}
 	}
@@ -1,44 +1,39 @@
 package main
 
-import "encoding/json"
+import (
+	"strconv"
+	"tee/store"
+)
 
-var sum = 0
-var product = 1
+// the results are kept in the store of the program, the TEE saves it after each execution
+// and loads it before the next one, so the program needs no GetStates and SetStates
 
 // Add is a function that performs addition
 func Add(a int) int {
-	sum += a
+	sum := load("sum", 0) + a
+	save("sum", sum)
 	return sum
 }
 
 // Mul is a function that performs multiplication
 func Mul(a int) int {
-	product *= a
+	product := load("product", 1) * a
+	save("product", product)
 	return product
 }
 
-type States struct {
-	Sum     int // global variable: addition result
-	Product int // global variable: multiplication result
-}
-
-// IMPORTANT: GetStates and SetStates must be defined in the user code
-// GetStates returns the current state witch will be saved and load lately by the VM using the SetStates function
-func GetStates() []byte {
-	res, err := json.Marshal(States{Sum: sum, Product: product})
-	if err != nil {
-		return nil
+func load(key string, initial int) int {
+	value, ok := store.Get(key)
+	if !ok {
+		return initial
 	}
-	return res
-}
-
-func SetStates(states []byte) {
-	var newStates States
-	err := json.Unmarshal(states, &newStates)
+	n, err := strconv.Atoi(string(value))
 	if err != nil {
-		return
+		return initial
 	}
+	return n
+}
 
-	sum = newStates.Sum
-	product = newStates.Product
+func save(key string, n int) {
+	store.Set(key, []byte(strconv.Itoa(n)))
 }
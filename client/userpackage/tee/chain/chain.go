@@ -0,0 +1,26 @@
+// Package chain declares the context the TEE gives golang programs, see tee/process/golang/vm/host.go.
+// The TEE replaces it when running a program, it only lets the programs of the userpackage folder build and vet.
+package chain
+
+// Caller returns the hex address of the caller, the calling program in a cross-program call
+func Caller() string { panic(hostOnly) }
+
+// ProgramAddress returns the hex address of the program
+func ProgramAddress() string { panic(hostOnly) }
+
+// BlockNumber returns the number of the block of the event
+func BlockNumber() uint64 { panic(hostOnly) }
+
+// BlockTime returns the time of the block of the event
+func BlockTime() uint64 { panic(hostOnly) }
+
+// Seed returns a deterministic random seed, the same in every TEE
+func Seed() int64 { panic(hostOnly) }
+
+// Call calls an exported function of a program returned by GetInteractContracts, args and result are JSON
+func Call(program, method string, args []byte) ([]byte, error) { panic(hostOnly) }
+
+// CallSolidity calls a solidity program, input and result are ABI encoded
+func CallSolidity(program string, input []byte) ([]byte, error) { panic(hostOnly) }
+
+const hostOnly = "tee/chain is only available to programs running in the TEE"
@@ -0,0 +1,8 @@
+// Package files declares the data files the TEE gives golang programs, see tee/process/golang/vm/package.go.
+// The TEE replaces it when running a program, it only lets the programs of the userpackage folder build and vet.
+package files
+
+// Read returns the content of a data file of the program, by its path in the archive
+func Read(name string) ([]byte, error) { panic(hostOnly) }
+
+const hostOnly = "tee/files is only available to programs running in the TEE"
@@ -0,0 +1,3 @@
+module tee
+
+go 1.21
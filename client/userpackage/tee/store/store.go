@@ -0,0 +1,17 @@
+// Package store declares the key-value store the TEE gives golang programs, see tee/process/golang/vm/store.go.
+// The TEE replaces it when running a program, it only lets the programs of the userpackage folder build and vet.
+package store
+
+// Get returns the value of a key and whether it is set
+func Get(key string) ([]byte, bool) { panic(hostOnly) }
+
+// Set sets the value of a key
+func Set(key string, value []byte) { panic(hostOnly) }
+
+// Delete removes a key
+func Delete(key string) { panic(hostOnly) }
+
+// Keys returns the keys starting with prefix, sorted
+func Keys(prefix string) []string { panic(hostOnly) }
+
+const hostOnly = "tee/store is only available to programs running in the TEE"
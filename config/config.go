@@ -0,0 +1,150 @@
+// Configuration shared by the tee and client binaries, both modules import this one through a replace directive.
+// Values are taken from the defaults, the config file, environment variables and command line flags, in increasing priority.
+package config
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+type Config struct {
+	RPCURL        string `json:"rpcURL"`
+	ArtifactsDir  string `json:"artifactsDir"`  // directory of the contract artifacts exported by onChain
+	AccountsPath  string `json:"accountsPath"`  // accounts file, defaults to accounts.json in ArtifactsDir
+	MCAddress     string `json:"mcAddress"`     // management contract address, read from MCAddressPath when empty
+	MCAddressPath string `json:"mcAddressPath"` // defaults to managementAddress.json in ArtifactsDir
+	ChainID       uint64 `json:"chainID"`       // 0 means auto-detected from the node
+}
+
+const defaultConfigPath = "./config.json"
+
+// environment variables
+const (
+	envConfig        = "RACETEE_CONFIG"
+	envRPCURL        = "RACETEE_RPC_URL"
+	envArtifactsDir  = "RACETEE_ARTIFACTS"
+	envAccountsPath  = "RACETEE_ACCOUNTS"
+	envMCAddress     = "RACETEE_MC_ADDRESS"
+	envMCAddressPath = "RACETEE_MC_ADDRESS_PATH"
+	envChainID       = "RACETEE_CHAIN_ID"
+)
+
+// command line flags
+var (
+	configPath    string
+	rpcURL        string
+	artifactsDir  string
+	accountsPath  string
+	mcAddress     string
+	mcAddressPath string
+	chainID       uint64
+)
+
+func Default() Config {
+	return Config{
+		RPCURL:       "ws://127.0.0.1:8545",
+		ArtifactsDir: "./artifacts",
+	}
+}
+
+// RegisterFlags adds the configuration flags, must be called before flag.Parse
+func RegisterFlags(fs *flag.FlagSet) {
+	fs.StringVar(&configPath, "config", "", "Config file (default "+defaultConfigPath+" if it exists)")
+	fs.StringVar(&rpcURL, "rpc", "", "RPC endpoint of the Ethereum node")
+	fs.StringVar(&artifactsDir, "artifacts", "", "Directory of the contract artifacts")
+	fs.StringVar(&accountsPath, "accounts", "", "Accounts file")
+	fs.StringVar(&mcAddress, "mcAddress", "", "Management contract address")
+	fs.StringVar(&mcAddressPath, "mcAddressPath", "", "File containing the management contract address")
+	fs.Uint64Var(&chainID, "chainID", 0, "Chain ID, detected from the node when 0")
+}
+
+// Load builds the configuration, must be called after flag.Parse
+func Load(fs *flag.FlagSet) (Config, error) {
+	conf := Default()
+
+	// config file
+	path := os.Getenv(envConfig)
+	if configPath != "" {
+		path = configPath
+	}
+	if path != "" {
+		err := loadFile(path, &conf)
+		if err != nil {
+			return conf, err
+		}
+	} else if _, err := os.Stat(defaultConfigPath); err == nil {
+		err = loadFile(defaultConfigPath, &conf)
+		if err != nil {
+			return conf, err
+		}
+	}
+
+	// environment variables
+	setFromEnv(envRPCURL, &conf.RPCURL)
+	setFromEnv(envArtifactsDir, &conf.ArtifactsDir)
+	setFromEnv(envAccountsPath, &conf.AccountsPath)
+	setFromEnv(envMCAddress, &conf.MCAddress)
+	setFromEnv(envMCAddressPath, &conf.MCAddressPath)
+	if v := os.Getenv(envChainID); v != "" {
+		id, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			return conf, fmt.Errorf("invalid %s: %v", envChainID, err)
+		}
+		conf.ChainID = id
+	}
+
+	// flags explicitly set on the command line
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "rpc":
+			conf.RPCURL = rpcURL
+		case "artifacts":
+			conf.ArtifactsDir = artifactsDir
+		case "accounts":
+			conf.AccountsPath = accountsPath
+		case "mcAddress":
+			conf.MCAddress = mcAddress
+		case "mcAddressPath":
+			conf.MCAddressPath = mcAddressPath
+		case "chainID":
+			conf.ChainID = chainID
+		}
+	})
+
+	// paths relative to the artifacts directory
+	if conf.AccountsPath == "" {
+		conf.AccountsPath = conf.Artifact("accounts")
+	}
+	if conf.MCAddressPath == "" {
+		conf.MCAddressPath = conf.Artifact("managementAddress")
+	}
+	return conf, nil
+}
+
+// Artifact returns the path of a contract artifact, e.g. Artifact("ManagementContract")
+func (c Config) Artifact(name string) string {
+	return filepath.Join(c.ArtifactsDir, name+".json")
+}
+
+func loadFile(path string, conf *Config) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %v", err)
+	}
+	err = json.Unmarshal(data, conf)
+	if err != nil {
+		return fmt.Errorf("failed to parse config file: %v", err)
+	}
+	return nil
+}
+
+func setFromEnv(name string, value *string) {
+	if v := os.Getenv(name); v != "" {
+		*value = v
+	}
+}
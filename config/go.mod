@@ -0,0 +1,3 @@
+module racetee/config
+
+go 1.21
@@ -1,15 +1,13 @@
 // SPDX-License-Identifier: MIT
 pragma solidity ^0.8.28;
 
-import "./SystemContract.sol";
-
 interface IERC20 {
     function transfer(address recipient, uint256 amount) external returns (bool);
     function transferFrom(address sender, address recipient, uint256 amount) external returns (bool);
     function balanceOf(address account) external view returns (uint256);
 }
 
-contract DEX is SystemContract {
+contract DEX {
     IERC20 public tokenA;
     IERC20 public tokenB;
     uint256 public totalLiquidity;
@@ -86,32 +84,4 @@ contract DEX is SystemContract {
         }
         return false;
     }
-
-    function getStates() external view override returns(bytes memory) {
-        uint256[] memory liquidityAmount = new uint256[](holders.length);
-        for (uint256 i = 0; i < holders.length; i++) {
-            liquidityAmount[i] = liquidity[holders[i]];
-        }
-        return abi.encode(tokenA, tokenB, totalLiquidity, holders, liquidityAmount);
-    }
-
-    function setStates(bytes memory _data) external override {
-        (IERC20 _tokenA, IERC20 _tokenB, uint256 _totalLiquidity, address[] memory _holders, uint256[] memory liquidityAmount) = abi.decode(_data, (IERC20, IERC20, uint256, address[], uint256[]));
-
-        tokenA = _tokenA;
-        tokenB = _tokenB;
-        totalLiquidity = _totalLiquidity;
-        holders = _holders;
-
-        for (uint256 i = 0; i < holders.length; i++) {
-            liquidity[holders[i]] = liquidityAmount[i];
-        }
-    }
-
-    function getInteractContracts() external view virtual override returns (address[] memory) {
-        address[] memory contracts = new address[](2);
-        contracts[0] = address(tokenA);
-        contracts[1] = address(tokenB);
-        return contracts;
-    }
 }
@@ -1,9 +1,7 @@
 // SPDX-License-Identifier: MIT
 pragma solidity ^0.8.28;
 
-import "./SystemContract.sol";
-
-contract ERC20 is SystemContract {
+contract ERC20 {
     string public name;
     string public symbol;
     uint8 public decimals;
@@ -106,49 +104,4 @@ contract ERC20 is SystemContract {
         }
         return false;
     }
-
-    function getStates() external view override returns (bytes memory)  {
-        uint256[] memory balancesArray = new uint256[](holders.length);
-        for (uint256 i = 0; i < holders.length; i++) {
-            balancesArray[i] = balances[holders[i]];
-        }
-
-        address[][] memory spenderArray = new address[][](allowanceOwners.length);
-        uint256[][] memory allowanceArray = new uint256[][](allowanceOwners.length);
-        for (uint256 i = 0; i < allowanceOwners.length; i++) {
-            address owner = allowanceOwners[i];
-            spenderArray[i] = spenders[owner];
-            allowanceArray[i] = new uint256[](spenders[owner].length);
-            for (uint256 j = 0; j < spenders[owner].length; j++) {
-                allowanceArray[i][j] = allowance[owner][spenders[owner][j]];
-            }
-        }
-
-        return abi.encode(name, symbol, decimals, totalSupply, holders, balancesArray, allowanceOwners, spenderArray, allowanceArray);
-    }
-
-    function setStates(bytes memory data) external override {
-        (string memory _name, string memory _symbol, uint8 _decimals, uint256 _totalSupply, address[] memory _holders, uint256[] memory _balancesArray, address[] memory _allowanceOwners, address[][] memory _spenderArray, uint256[][] memory _allowanceArray) =
-            abi.decode(data, (string, string, uint8, uint256, address[], uint256[], address[], address[][], uint256[][]));
-
-        name = _name;
-        symbol = _symbol;
-        decimals = _decimals;
-        totalSupply = _totalSupply;
-
-        for (uint256 i = 0; i < _holders.length; i++) {
-            balances[_holders[i]] = _balancesArray[i];
-        }
-        holders = _holders;
-
-        for (uint256 i = 0; i < _allowanceOwners.length; i++) {
-            address owner = _allowanceOwners[i];
-            for (uint256 j = 0; j < _spenderArray[i].length; j++) {
-                address spender = _spenderArray[i][j];
-                allowance[owner][spender] = _allowanceArray[i][j];
-                spenders[owner].push(spender);
-            }
-        }
-        allowanceOwners = _allowanceOwners;
-    }
 }
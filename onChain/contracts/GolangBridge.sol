@@ -0,0 +1,16 @@
+// SPDX-License-Identifier: MIT
+pragma solidity ^0.8.28;
+
+// precompile of the TEE through which solidity programs call golang programs
+// args and the result are JSON, args is an array with one element per parameter
+interface IGolangBridge {
+    function callGolang(address program, string calldata method, bytes calldata args) external returns (bytes memory);
+}
+
+library GolangBridge {
+    IGolangBridge constant BRIDGE = IGolangBridge(0x0000000000000000000000000000000000001000);
+
+    function call(address program, string memory method, bytes memory args) internal returns (bytes memory) {
+        return BRIDGE.callGolang(program, method, args);
+    }
+}
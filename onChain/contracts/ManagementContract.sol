@@ -145,20 +145,22 @@ contract ManagementContract {
         _;
     }
     // The following functions are used for indirect calls from the program contract
-    event Deploy(bytes encryptedCode, bytes encryptedConfig, bytes transactionKey, address caller, address programAddress);
-    function deploy(bytes calldata encryptedCode, bytes calldata encryptedConfig, bytes calldata transactionKey, address caller) external payable{
+    // encryptedResultKey is used by the TEE to report a failed deployment to the deployer
+    event Deploy(bytes encryptedCode, bytes encryptedConfig, bytes encryptedResultKey, bytes transactionKey, address caller, address programAddress);
+    function deploy(bytes calldata encryptedCode, bytes calldata encryptedConfig, bytes calldata encryptedResultKey, bytes calldata transactionKey, address caller) external payable{
         // TODO: check transaction fee is enough
-        emit Deploy(encryptedCode, encryptedConfig, transactionKey, caller, msg.sender);
+        emit Deploy(encryptedCode, encryptedConfig, encryptedResultKey, transactionKey, caller, msg.sender);
     }
     event Execution(bytes encryptedInput, bytes encryptedResultKey, bytes transactionKey, address caller, address programAddress);
     function execution(bytes calldata encryptedInput, bytes calldata encryptedResultKey, bytes calldata transactionKey, address caller) external payable validCall(msg.sender){
         // TODO: check transaction fee is enough
         emit Execution(encryptedInput, encryptedResultKey, transactionKey, caller, msg.sender);
     }
-    event ACL(bytes encryptedInput, bytes transactionKey, address caller, address programAddress);
-    function changeACL(bytes calldata encryptedInput, bytes calldata transactionKey, address caller) external payable validCall(msg.sender){
+    // encryptedResultKey is used by the TEE to report a rejected ACL change to the caller
+    event ACL(bytes encryptedInput, bytes encryptedResultKey, bytes transactionKey, address caller, address programAddress);
+    function changeACL(bytes calldata encryptedInput, bytes calldata encryptedResultKey, bytes calldata transactionKey, address caller) external payable validCall(msg.sender){
         // TODO: check transaction fee is enough
-        emit ACL(encryptedInput, transactionKey, caller, msg.sender);
+        emit ACL(encryptedInput, encryptedResultKey, transactionKey, caller, msg.sender);
     }
 
     // verify signature is generated by corresponding public key
@@ -7,8 +7,9 @@ contract ProgramContract is StandardProgramContract {
 	constructor(
         bytes memory encryptedCode,
         bytes memory encrytedConfig,
+        bytes memory encryptedResultKey,
         bytes memory transactionKey,
         address MCAddress) payable
-        StandardProgramContract(encryptedCode, encrytedConfig, transactionKey, MCAddress) {
+        StandardProgramContract(encryptedCode, encrytedConfig, encryptedResultKey, transactionKey, MCAddress) {
 	}
 }
\ No newline at end of file
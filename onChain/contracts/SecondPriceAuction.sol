@@ -1,14 +1,12 @@
 // SPDX-License-Identifier: MIT
 pragma solidity ^0.8.0;
 
-import "./SystemContract.sol";
-
 interface IERC20 {
     function transfer(address recipient, uint256 amount) external returns (bool);
     function transferFrom(address sender, address recipient, uint256 amount) external returns (bool);
 }
 
-contract SecondPriceAuction is SystemContract {
+contract SecondPriceAuction {
     IERC20 public token;
     address private seller;
     uint256 private biddingEnd;
@@ -77,27 +75,4 @@ contract SecondPriceAuction is SystemContract {
         return bids[_account] > 0;
     }
 
-    function getStates() external view override returns (bytes memory)  {
-        uint256[] memory bidsArr = new uint256[](bidders.length);
-        for (uint256 i = 0; i < bidders.length; i++) {
-            bidsArr[i] = bids[bidders[i]];
-        }
-        return abi.encode(token, seller, biddingEnd, highestBidder, highestBid, secondHighestBid, auctionEnded, bidders, bidsArr);
-    }
-
-    function setStates(bytes memory _states) external override {
-        uint256[] memory bidsArr;
-        (token, seller, biddingEnd, highestBidder, highestBid, secondHighestBid, auctionEnded, bidders, bidsArr) = abi.decode(_states, (IERC20, address, uint256, address, uint256, uint256, bool, address[], uint256[]));
-
-        for (uint256 i = 0; i < bidders.length; i++) {
-            bids[bidders[i]] = bidsArr[i];
-        }
-    }
-
-    function getInteractContracts() external view virtual override returns (address[] memory) {
-        address[] memory contracts = new address[](1);
-        contracts[0] = address(token);
-        return contracts;
-    }
-
 }
@@ -11,18 +11,19 @@ abstract contract StandardProgramContract {
     // The encryption key is transactionPubKey from the Management contract
     constructor(bytes memory encryptedCode,
         bytes memory encryptedConfig, 
+        bytes memory encryptedResultKey,
         bytes memory transactionKey,
         address _MCAddress) payable {
         MCAdress = _MCAddress;
         MC = ManagementContract(_MCAddress);
-        MC.deploy{value: msg.value}(encryptedCode, encryptedConfig, transactionKey, msg.sender);
+        MC.deploy{value: msg.value}(encryptedCode, encryptedConfig, encryptedResultKey, transactionKey, msg.sender);
     }
 
 	function execution(bytes calldata encrytedInput, bytes calldata encryptedResultKey, bytes calldata transactionKey) external payable {
         MC.execution{value: msg.value}(encrytedInput, encryptedResultKey, transactionKey, msg.sender);
 	}
-	function changeACL(bytes calldata encrytedInput, bytes calldata transactionKey) external payable {
-        MC.changeACL{value: msg.value}(encrytedInput, transactionKey, msg.sender);
+	function changeACL(bytes calldata encrytedInput, bytes calldata encryptedResultKey, bytes calldata transactionKey) external payable {
+        MC.changeACL{value: msg.value}(encrytedInput, encryptedResultKey, transactionKey, msg.sender);
 	}
 	
 	// Set code and states, called only by the Management contract
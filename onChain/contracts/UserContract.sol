@@ -1,9 +1,7 @@
 // SPDX-License-Identifier: MIT
 pragma solidity ^0.8.28;
 
-import "./SystemContract.sol";
-
-contract UserContract is SystemContract {
+contract UserContract {
     uint sum = 0;
     uint product = 1;
     struct Person {
@@ -36,24 +34,6 @@ contract UserContract is SystemContract {
         return product;
     }
 
-    function getStates() external override view returns (bytes memory) {
-        uint[] memory keyArray;
-        Person[] memory valueArray;
-        (keyArray, valueArray) = processMapping();
-        return abi.encode(sum, product, staticValues, dynamicValues, keyArray, valueArray);
-    }
-
-    function setStates(bytes memory data) external override {
-        uint[] memory keyArray;
-        Person[] memory valueArray;
-        (sum, product, staticValues, dynamicValues, keyArray, valueArray) = abi.decode(data, (uint, uint, uint[3], uint[], uint[], Person[]));
-
-        for (uint i = 0; i < keyArray.length; i++) {
-            personMap[keyArray[i]] = valueArray[i];
-        }
-        keys = keyArray;
-    }
-
     function processMapping() public view returns (uint[] memory key, Person[] memory valueArray) {
         uint len = keys.length;
         valueArray = new Person[](len);
@@ -1,10 +1,9 @@
 // SPDX-License-Identifier: MIT
 pragma solidity ^0.8.28;
 
-import "./SystemContract.sol";
 import "./UserContract.sol";
 
-contract UserContract2 is SystemContract {
+contract UserContract2 {
     uint sum = 0;
 
     UserContract public userContract;
@@ -17,18 +16,4 @@ contract UserContract2 is SystemContract {
         userContract.multiply(a);
         return sum;
     }
-
-    function getStates() external override view returns (bytes memory) {
-        return abi.encode(sum, userContract);
-    }
-
-    function setStates(bytes memory data) external override {
-        (sum, userContract) = abi.decode(data, (uint, UserContract));
-    }
-
-    function getInteractContracts () external view override returns (address[] memory) {
-        address[] memory contracts = new address[](1);
-        contracts[0] = address(userContract);
-        return contracts;
-    }
 }
\ No newline at end of file
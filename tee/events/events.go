@@ -29,24 +29,27 @@ import (
 	"github.com/ethereum/go-ethereum/ethclient"
 )
 
-func GetEventsFrom(start uint64, end uint64) []map[string]interface{} {
-	client := help.Client
-	parsedMCABI := help.ParsedMCABI
+func GetEventsFrom(chain *help.Chain, start uint64, end uint64) ([]map[string]interface{}, error) {
+	client := chain.Client
+	parsedMCABI := chain.ParsedMCABI
 
-	logs, err := getEvents(client, start, end)
+	logs, err := getEvents(client, chain.MCAddress, start, end)
 	if err != nil {
-		log.Fatalf("Failed to get logs: %v", err)
+		return nil, fmt.Errorf("failed to get logs: %v", err)
+	}
+	events, err := parseLogs(client, parsedMCABI, logs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse logs: %v", err)
 	}
-	events := parseLogs(client, parsedMCABI, logs)
 
-	return events
+	return events, nil
 }
 
-func getEvents(client *ethclient.Client, fromBlock uint64, toBlock uint64) ([]types.Log, error) {
+func getEvents(client *ethclient.Client, MCAddress string, fromBlock uint64, toBlock uint64) ([]types.Log, error) {
 	query := ethereum.FilterQuery{
 		FromBlock: big.NewInt(int64(fromBlock)),
 		ToBlock:   big.NewInt(int64(toBlock)),
-		Addresses: []common.Address{common.HexToAddress(help.MCAddress)},
+		Addresses: []common.Address{common.HexToAddress(MCAddress)},
 	}
 
 	logs, err := client.FilterLogs(context.Background(), query)
@@ -56,7 +59,7 @@ func getEvents(client *ethclient.Client, fromBlock uint64, toBlock uint64) ([]ty
 	return logs, nil
 }
 
-func parseLogs(client *ethclient.Client, parsedABI abi.ABI, logs []types.Log) []map[string]interface{} {
+func parseLogs(client *ethclient.Client, parsedABI abi.ABI, logs []types.Log) ([]map[string]interface{}, error) {
 	var parsedEvents []map[string]interface{}
 
 	for _, vLog := range logs {
@@ -66,6 +69,8 @@ func parseLogs(client *ethclient.Client, parsedABI abi.ABI, logs []types.Log) []
 			eventName = "Deploy"
 		case parsedABI.Events["Execution"].ID.Hex():
 			eventName = "Execution"
+		case parsedABI.Events["ACL"].ID.Hex():
+			eventName = "ACL"
 		default:
 			continue // ignore unknown event
 		}
@@ -84,8 +89,8 @@ func parseLogs(client *ethclient.Client, parsedABI abi.ABI, logs []types.Log) []
 		blockNumber := big.NewInt(int64(vLog.BlockNumber))
 		block, err := client.BlockByNumber(context.Background(), blockNumber)
 		if err != nil {
-			log.Printf("Failed to get block by number: %v", err)
-			continue
+			// do not skip the event, the whole range is retried
+			return nil, fmt.Errorf("failed to get block by number: %v", err)
 		}
 
 		// add addional information
@@ -98,5 +103,5 @@ func parseLogs(client *ethclient.Client, parsedABI abi.ABI, logs []types.Log) []
 		parsedEvents = append(parsedEvents, data)
 	}
 
-	return parsedEvents
+	return parsedEvents, nil
 }
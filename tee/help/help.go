@@ -5,9 +5,9 @@ import (
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
-	"log"
 	"math/big"
 
+	"racetee/config"
 	"strings"
 
 	"github.com/ethereum/go-ethereum"
@@ -37,33 +37,23 @@ type Output struct {
 	TransType          uint8          `abi:"transType"`
 }
 
-var (
-	Lang string
-
-	RPCURL         = "ws://127.0.0.1:8545"
-	MCABIPath      = "./artifacts/ManagementContract.json"
-	ProgramABIPath = "./artifacts/StandardProgramContract.json"
-	SystemABIPath  = "./artifacts/SystemContract.json"
-	AccountsPath   = "./artifacts/accounts.json"
-	MCAddressPath  = "./artifacts/managementAddress.json"
-
-	MCAddress        string
+// Chain is the connection to the node and the contracts used by a TEE
+type Chain struct {
+	RPCURL           string
 	Client           *ethclient.Client
+	MCAddress        string
 	ParsedMCABI      abi.ABI
 	ParsedProgramABI abi.ABI
-	ParsedSystemABI  abi.ABI
 	Accounts         []Account
+	ChainID          *big.Int
+}
 
+var (
 	TransTypeDeploy    uint8
 	TransTypeExecution uint8
 	TransTypeInteract  uint8
 	TransTypeACL       uint8
 	TransTypeError     uint8
-
-	ChainID      *big.Int
-	AccountIndex int
-
-	AverageTimes int
 )
 
 func init() {
@@ -72,131 +62,167 @@ func init() {
 	TransTypeInteract = uint8(2)
 	TransTypeACL = uint8(3)
 	TransTypeError = uint8(4)
-	getClient()
-	MCAddress = loadAddress(MCAddressPath)
-	ParsedMCABI = LoadABI(MCABIPath)
-	ParsedProgramABI = LoadABI(ProgramABIPath)
-	ParsedSystemABI = LoadABI(SystemABIPath)
-	Accounts = LoadAccounts()
-
-	// ChainID = big.NewInt(1337) // for ganache
-	ChainID = big.NewInt(31337)
 }
 
-func getClient() *ethclient.Client {
-	client, err := ethclient.Dial(RPCURL)
+// NewChain connects to the node and loads the contracts and accounts of the configuration
+func NewChain(conf config.Config) (*Chain, error) {
+	var err error
+	chain := &Chain{RPCURL: conf.RPCURL}
+
+	chain.Client, err = ethclient.Dial(conf.RPCURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to the Ethereum client: %v", err)
+	}
+	chain.MCAddress = conf.MCAddress
+	if chain.MCAddress == "" {
+		chain.MCAddress, err = loadAddress(conf.MCAddressPath)
+		if err != nil {
+			return nil, err
+		}
+	}
+	chain.ParsedMCABI, err = LoadABI(conf.Artifact("ManagementContract"))
+	if err != nil {
+		return nil, err
+	}
+	chain.ParsedProgramABI, err = LoadABI(conf.Artifact("StandardProgramContract"))
+	if err != nil {
+		return nil, err
+	}
+	chain.Accounts, err = LoadAccounts(conf.AccountsPath)
+	if err != nil {
+		return nil, err
+	}
+
+	// detect the chain ID from the node when not configured (31337 for hardhat, 1337 for ganache)
+	if conf.ChainID != 0 {
+		chain.ChainID = new(big.Int).SetUint64(conf.ChainID)
+	} else {
+		chain.ChainID, err = chain.Client.NetworkID(context.Background())
+		if err != nil {
+			return nil, fmt.Errorf("failed to get chain ID: %v", err)
+		}
+	}
+	return chain, nil
+}
+
+// Reconnect dials the RPC endpoint again and replaces the client
+func (c *Chain) Reconnect() error {
+	client, err := ethclient.Dial(c.RPCURL)
 	if err != nil {
-		log.Fatalf("Failed to connect to the Ethereum client: %v", err)
+		return fmt.Errorf("failed to connect to the Ethereum client: %v", err)
+	}
+	if c.Client != nil {
+		c.Client.Close()
 	}
-	Client = client
-	return client
+	c.Client = client
+	return nil
 }
 
 // load accounts from JSON file
-func LoadAccounts() []Account {
-	data, err := ioutil.ReadFile(AccountsPath)
+func LoadAccounts(path string) ([]Account, error) {
+	data, err := ioutil.ReadFile(path)
 	if err != nil {
-		log.Fatalf("Failed to read JSON file: %v", err)
+		return nil, fmt.Errorf("failed to read JSON file: %v", err)
 	}
 
 	// parse JSON
 	var accounts []Account
 	err = json.Unmarshal(data, &accounts)
 	if err != nil {
-		log.Fatalf("Failed to parse JSON: %v", err)
+		return nil, fmt.Errorf("failed to parse JSON: %v", err)
 	}
-	return accounts
+	return accounts, nil
 }
 
-func LoadABI(path string) abi.ABI {
+func LoadABI(path string) (abi.ABI, error) {
 	data, err := ioutil.ReadFile(path)
 	if err != nil {
-		log.Fatalf("Failed to read JSON file: %v", err)
+		return abi.ABI{}, fmt.Errorf("failed to read JSON file: %v", err)
 	}
 
 	// parse JSON
 	var contractConfig map[string]interface{}
 	err = json.Unmarshal(data, &contractConfig)
 	if err != nil {
-		log.Fatalf("Failed to parse JSON: %v", err)
+		return abi.ABI{}, fmt.Errorf("failed to parse JSON: %v", err)
 	}
 
 	// get ABI field
 	abiField, ok := contractConfig["abi"]
 	if !ok {
-		log.Fatalf("ABI field not found in JSON")
+		return abi.ABI{}, fmt.Errorf("ABI field not found in JSON")
 	}
 
 	// marshal ABI field
 	abiJSON, err := json.Marshal(abiField)
 	if err != nil {
-		log.Fatalf("Failed to marshal ABI field: %v", err)
+		return abi.ABI{}, fmt.Errorf("failed to marshal ABI field: %v", err)
 	}
 
 	// parse ABI
 	parsedABI, err := abi.JSON(strings.NewReader(string(abiJSON)))
 	if err != nil {
-		log.Fatalf("Failed to parse ABI: %v", err)
+		return abi.ABI{}, fmt.Errorf("failed to parse ABI: %v", err)
 	}
-	return parsedABI
+	return parsedABI, nil
 }
 
 // signs a transaction with the given private key
-func SignTransaction(client *ethclient.Client, privateKeyHex string, tx *types.Transaction) (*types.Transaction, error) {
+func (c *Chain) SignTransaction(privateKeyHex string, tx *types.Transaction) (*types.Transaction, error) {
 	privateKey, err := crypto.HexToECDSA(privateKeyHex)
 	if err != nil {
 		return nil, fmt.Errorf("invalid private key: %v", err)
 	}
 
 	var chainID *big.Int
-	if ChainID != nil {
-		chainID = ChainID
+	if c.ChainID != nil {
+		chainID = c.ChainID
 	} else {
-		chainID, err = client.NetworkID(context.Background())
+		chainID, err = c.Client.NetworkID(context.Background())
 		if err != nil {
 			return nil, fmt.Errorf("failed to get chain ID: %v", err)
 		}
 	}
 
-	return types.SignTx(tx, types.NewEIP155Signer(chainID), privateKey)
+	return types.SignTx(tx, types.LatestSignerForChainID(chainID), privateKey)
 }
 
 // load bytcode from JSON file
-func LoadBytecode(path string) string {
+func LoadBytecode(path string) (string, error) {
 	data, err := ioutil.ReadFile(path)
 	if err != nil {
-		log.Fatalf("Failed to read JSON file: %v", err)
+		return "", fmt.Errorf("failed to read JSON file: %v", err)
 	}
 
 	// parse JSON
 	var contractConfig map[string]interface{}
 	err = json.Unmarshal(data, &contractConfig)
 	if err != nil {
-		log.Fatalf("Failed to parse JSON: %v", err)
+		return "", fmt.Errorf("failed to parse JSON: %v", err)
 	}
 
 	// get bytecode
 	bytecode, ok := contractConfig["bytecode"]
 	if !ok {
-		log.Fatalf("bytecode field not found in JSON")
+		return "", fmt.Errorf("bytecode field not found in JSON")
 	}
 
-	return bytecode.(string)
+	return bytecode.(string), nil
 }
 
-func loadAddress(filePath string) string {
+func loadAddress(filePath string) (string, error) {
 	// read and parse JSON file
 	data, err := ioutil.ReadFile(filePath)
 	if err != nil {
-		log.Fatalf("Failed to read JSON file: %v", err)
+		return "", fmt.Errorf("failed to read JSON file: %v", err)
 	}
 	var result Address
 	err = json.Unmarshal(data, &result)
 	if err != nil {
-		log.Fatalf("Failed to parse JSON: %v", err)
+		return "", fmt.Errorf("failed to parse JSON: %v", err)
 	}
 
-	return result.Address
+	return result.Address, nil
 }
 
 func ErrorOutput(errMsg string, programAddress common.Address, key []byte) Output {
@@ -215,7 +241,7 @@ func ByteToByte32(b []byte) [32]byte {
 	return bytes32
 }
 
-func CallContractMethod(parsedABI abi.ABI, contractAddr common.Address, methodName string, params []interface{}, output interface{}) error {
+func (c *Chain) CallContractMethod(parsedABI abi.ABI, contractAddr common.Address, methodName string, params []interface{}, output interface{}) error {
 	// encode call data
 	callData, err := parsedABI.Pack(methodName, params...)
 	if err != nil {
@@ -223,7 +249,7 @@ func CallContractMethod(parsedABI abi.ABI, contractAddr common.Address, methodNa
 	}
 
 	// call contract
-	result, err := Client.CallContract(context.Background(), ethereum.CallMsg{
+	result, err := c.Client.CallContract(context.Background(), ethereum.CallMsg{
 		To:   &contractAddr,
 		Data: callData,
 	}, nil)
@@ -18,30 +18,37 @@ import (
 	"github.com/ethereum/go-ethereum/crypto/ecies"
 )
 
-var (
-	PrivateKey *ecdsa.PrivateKey
-	PublicKey  *ecdsa.PublicKey
+const (
+	DefaultMgtKeyPath = "./key/tempMgtKey.json"
+	DefaultTxKeyPath  = "./key/tempTxKey.json"
 )
 
-var mapTXKey = make(map[string]*ecies.PrivateKey)
-
-var KeyMgt string
+// Keys are the keys owned by one TEE
+type Keys struct {
+	PrivateKey *ecdsa.PrivateKey
+	PublicKey  *ecdsa.PublicKey
+	KeyMgt     string
+	mapTXKey   map[string]*ecies.PrivateKey
+}
 
-func init() {
+// NewKeys generates the TEE key pair and loads the management and transaction keys
+func NewKeys(mgtKeyPath string, txKeyPath string) (*Keys, error) {
 	var err error
-	PrivateKey, err = generateECDHKey()
-	PublicKey = &PrivateKey.PublicKey
+	keys := &Keys{mapTXKey: make(map[string]*ecies.PrivateKey)}
+	keys.PrivateKey, err = generateECDHKey()
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
-	KeyMgt, err = loadKeyFromFile("./key/tempMgtKey.json")
+	keys.PublicKey = &keys.PrivateKey.PublicKey
+	keys.KeyMgt, err = loadKeyFromFile(mgtKeyPath)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
-	_, _, err = loadTXKeyFromFile("./key/tempTxKey.json")
+	_, _, err = keys.loadTXKeyFromFile(txKeyPath)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
+	return keys, nil
 }
 
 // GenerateECDHKey generates an ECDH private key
@@ -64,8 +71,8 @@ func PublicKeyToAddress(pubKey *ecdsa.PublicKey) common.Address {
 	return common.BytesToAddress(hash[12:]) // take the last 20 bytes
 }
 
-func TEESign(message []byte) ([]byte, error) {
-	signature, err := crypto.Sign(message, PrivateKey)
+func (k *Keys) TEESign(message []byte) ([]byte, error) {
+	signature, err := crypto.Sign(message, k.PrivateKey)
 	if err != nil {
 		return nil, fmt.Errorf("failed to sign message: %v", err)
 	}
@@ -203,7 +210,7 @@ type TXKeyFile struct {
 }
 
 // read the AES key (fixed key, not implement the TXKey change for prototype) and parse it into an ECIES key pair
-func loadTXKeyFromFile(filePath string) (*ecies.PrivateKey, *ecies.PublicKey, error) {
+func (k *Keys) loadTXKeyFromFile(filePath string) (*ecies.PrivateKey, *ecies.PublicKey, error) {
 	data, err := ioutil.ReadFile(filePath)
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to read key file: %w", err)
@@ -240,13 +247,13 @@ func loadTXKeyFromFile(filePath string) (*ecies.PrivateKey, *ecies.PublicKey, er
 	eciesPrivateKey := ecies.ImportECDSA(privateKey)
 	eciesPublicKey := ecies.ImportECDSAPublic((*ecdsa.PublicKey)(publicKey))
 
-	mapTXKey[hex.EncodeToString(pubKeyBytes)] = eciesPrivateKey
+	k.mapTXKey[hex.EncodeToString(pubKeyBytes)] = eciesPrivateKey
 
 	return eciesPrivateKey, eciesPublicKey, nil
 }
 
-func ECIESDecrypt(cipherText []byte, pubKey string) ([]byte, error) {
-	TXPrivateKey := mapTXKey[pubKey]
+func (k *Keys) ECIESDecrypt(cipherText []byte, pubKey string) ([]byte, error) {
+	TXPrivateKey := k.mapTXKey[pubKey]
 	if TXPrivateKey == nil {
 		return nil, fmt.Errorf("failed to get TXPrivateKey")
 	}
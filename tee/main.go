@@ -1,107 +1,38 @@
 package main
 
 import (
-	"context"
 	"flag"
-	"fmt"
 	"log"
-	"math/big"
-	"math/rand"
-	"strconv"
-	"tee/events"
-	"tee/help"
-	"tee/key"
-	"tee/operation"
-	"tee/process"
-	"tee/pull"
-
-	"github.com/ethereum/go-ethereum/core/types"
+	"racetee/config"
+	"tee/node"
+	"tee/runner"
 )
 
-// ./tee -lang s
+// ./tee -i 5
 func main() {
-	var lang string
-	var i string
-	flag.StringVar(&lang, "lang", "s", "User program language: g(golang) or s(solidity)")
-	flag.StringVar(&i, "i", "5", "Account index")
+	opts := node.DefaultOptions()
+	flag.IntVar(&opts.AccountIndex, "i", opts.AccountIndex, "Account index")
+	flag.Uint64Var(&opts.Confirmations, "confirmations", 0, "Number of blocks an event must be buried under before it is executed")
+	flag.DurationVar(&opts.MaxTimeout, "maxTimeout", opts.MaxTimeout, "Maximum execution time of a Go program")
+	var maxMemory uint
+	flag.UintVar(&maxMemory, "maxMemory", uint(opts.MaxMemoryMB), "Maximum memory in MB of a Go program")
+	flag.Uint64Var(&opts.MaxGas, "maxGas", opts.MaxGas, "Maximum gas of an execution in the EVM")
+	config.RegisterFlags(flag.CommandLine)
 	flag.Parse()
-	help.Lang = lang
-	help.AccountIndex, _ = strconv.Atoi(i)
-	register()
-	// wait for the TEE to be registered
-	// time.Sleep(20 * time.Second)
-	start()
-}
-
-func register() {
-	account := help.Accounts[help.AccountIndex]
-
-	// Register the TEE on chain
-	teePK := key.FormatECDSAPublicKey(key.PublicKey)
-	// teePkHash := sha256.Sum256(teePK)
-	// localQuote := quote.GetQuote(teePkHash[:])
-	// generate a fake quote
-	localQuote := make([]byte, 0)
-	_, err := rand.Read(localQuote)
-	if err != nil {
-		panic(err)
-	}
-	err = operation.CallRegister(localQuote, teePK, big.NewInt(1000000000000000000), account)
-	if err != nil {
-		panic(err)
-	}
-}
-
-func start() {
-	client := help.Client
-	account := help.Accounts[help.AccountIndex]
-
-	// Get the latest block number to run TEE first time
-	blockNumber, err := client.BlockNumber(context.Background())
-	if err != nil {
-		log.Fatalf("Failed to get the latest block number: %v", err)
-	}
-	running(account, blockNumber)
-
-	// Create a channel to receive new block headers
-	headers := make(chan *types.Header)
-	sub, err := client.SubscribeNewHead(context.Background(), headers)
+	opts.MaxMemoryMB = uint32(maxMemory)
+	conf, err := config.Load(flag.CommandLine)
 	if err != nil {
-		log.Fatalf("Failed to subscribe to new head: %v", err)
-	}
-	defer sub.Unsubscribe()
-
-	// Process each new block as it arrives
-	for {
-		select {
-		case err := <-sub.Err():
-			log.Fatalf("Error while subscribing to new head: %v", err)
-		case header := <-headers:
-			running(account, header.Number.Uint64())
-		}
+		log.Fatalf("Failed to load config: %v", err)
 	}
-}
-
-func running(account help.Account, end uint64) {
-	startBlock, err := pull.GetLatestExecutionBlock()
+	n, err := node.New(conf, opts)
 	if err != nil {
-		panic(err)
-	}
-	// retrieve all events from the last execution block to the current block
-	latest := (*startBlock).BlockNumber
-	start := latest + 1
-	fmt.Printf("Start: %v, End: %v\n", start, end)
-	eventsList := events.GetEventsFrom(start, end)
-	fmt.Printf("evetnsLength: %v\n", len(eventsList))
-	// if there are no events, return
-	if len(eventsList) == 0 {
-		return
+		log.Fatalf("Failed to create TEE node: %v", err)
 	}
-
-	// process all events
-	outputs := process.Process(eventsList)
-	err = process.SendOutputsToChain(account, outputs, latest, end)
+	err = runner.Register(n)
 	if err != nil {
-		panic(err)
+		log.Fatalf("Failed to register TEE: %v", err)
 	}
+	// wait for the TEE to be registered
+	// time.Sleep(20 * time.Second)
+	runner.Run(n)
 }
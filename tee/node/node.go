@@ -0,0 +1,97 @@
+// A TEE node owns everything one TEE instance needs, so that several instances can run in one process.
+package node
+
+import (
+	"fmt"
+	"racetee/config"
+	"tee/help"
+	"tee/key"
+	"tee/ocs"
+	"tee/process/cache"
+	"tee/process/golang/vm"
+	"tee/reorg"
+	"tee/submission"
+	"tee/txmgr"
+	"time"
+)
+
+type Options struct {
+	AccountIndex  int
+	Confirmations uint64 // blocks an event must be buried under before it is executed
+	MgtKeyPath    string
+	TxKeyPath     string
+	MaxTimeout    time.Duration // ceiling of the execution time of Go programs, also the default
+	MaxMemoryMB   uint32        // ceiling of the memory of Go programs, also the default
+	MaxGas        uint64        // ceiling of the gas of an execution in the EVM, also the default
+	Remote        ocs.Remote    // off-chain storage the outputs of rival TEEs are pulled from, e.g. the Local view of another node
+}
+
+type Node struct {
+	Chain      *help.Chain
+	Keys       *key.Keys
+	Cache      *cache.Cache
+	OCS        *ocs.Store
+	Txs        *txmgr.Manager
+	Reorg      *reorg.Tracker
+	Submission *submission.Tracker
+	Programs   *vm.Pool // interpreters of the Go programs reused between executions
+
+	Account       help.Account
+	Confirmations uint64
+	MaxTimeout    time.Duration
+	MaxMemoryMB   uint32
+	MaxGas        uint64
+}
+
+func DefaultOptions() Options {
+	return Options{
+		AccountIndex: 5,
+		MgtKeyPath:   key.DefaultMgtKeyPath,
+		TxKeyPath:    key.DefaultTxKeyPath,
+		MaxTimeout:   5 * time.Second,
+		MaxMemoryMB:  256,
+		MaxGas:       1000000000,
+	}
+}
+
+// New connects to the node of the configuration and creates the keys and storage of a TEE
+func New(conf config.Config, opts Options) (*Node, error) {
+	chain, err := help.NewChain(conf)
+	if err != nil {
+		return nil, err
+	}
+	return NewWithChain(chain, opts)
+}
+
+// NewWithChain creates a TEE on an existing connection, TEEs in one process may share it
+func NewWithChain(chain *help.Chain, opts Options) (*Node, error) {
+	if opts.AccountIndex < 0 || opts.AccountIndex >= len(chain.Accounts) {
+		return nil, fmt.Errorf("account index %v out of range", opts.AccountIndex)
+	}
+	keys, err := key.NewKeys(opts.MgtKeyPath, opts.TxKeyPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load keys: %v", err)
+	}
+
+	account := chain.Accounts[opts.AccountIndex]
+	store := ocs.New()
+	store.SetRemote(opts.Remote)
+	c := cache.New()
+	txs := txmgr.New(chain, account)
+	return &Node{
+		Chain:      chain,
+		Keys:       keys,
+		Cache:      c,
+		OCS:        store,
+		Txs:        txs,
+		Reorg:      reorg.New(chain, store, c),
+		Submission: submission.New(txs, store, c),
+		Programs:   vm.NewPool(vm.DefaultPoolPrograms),
+
+		Account:       account,
+		Confirmations: opts.Confirmations,
+		MaxTimeout:    opts.MaxTimeout,
+		MaxMemoryMB:   opts.MaxMemoryMB,
+		MaxGas:        opts.MaxGas,
+	}, nil
+}
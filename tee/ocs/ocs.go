@@ -1,52 +1,207 @@
 // simulation for off-chain storage (should be implemented through disk rather than memory)
 package ocs
 
-import "github.com/ethereum/go-ethereum/common"
+import (
+	"sync"
 
-var codes = map[common.Address][]byte{}
-var states = map[common.Address]map[string][]byte{}
-var info = map[common.Address]map[string][]byte{}
+	"github.com/ethereum/go-ethereum/common"
+)
 
-func GetCode(addr common.Address) []byte {
-	if code, exists := codes[addr]; exists {
-		// return a copy of the code to prevent modification from outside
-		return append([]byte{}, code...)
+type Store struct {
+	// the store is read by the other TEEs of the process through Local
+	mu     sync.RWMutex
+	codes  map[common.Address][]byte
+	states map[common.Address]map[string][]byte
+	info   map[common.Address]map[string][]byte
+
+	// journal of all writes, used to discard the writes of orphaned blocks after a reorg
+	journal     []journalEntry
+	journalBase int
+
+	remote Remote // storage the outputs of the other TEEs are pulled from, may be nil
+}
+
+// Remote is the off-chain storage shared by the TEEs, the outputs of a rival TEE are pulled from it.
+// TEEs running in one process can pull from each other through Store.Local.
+type Remote interface {
+	GetCode(addr common.Address) []byte
+	GetStates(addr common.Address, hash []byte) []byte
+	GetInfo(addr common.Address, hash []byte) []byte
+}
+
+type journalEntry struct {
+	store map[common.Address]map[string][]byte // nil for codes
+	addr  common.Address
+	hash  string
+}
+
+func New() *Store {
+	return &Store{
+		codes:   map[common.Address][]byte{},
+		states:  map[common.Address]map[string][]byte{},
+		info:    map[common.Address]map[string][]byte{},
+		journal: []journalEntry{},
 	}
-	return nil
 }
 
-func SetCode(addr common.Address, code []byte) {
-	if codes[addr] == nil {
-		codes[addr] = code
+// SetRemote sets the storage the entries missing here are pulled from
+func (s *Store) SetRemote(r Remote) {
+	s.remote = r
+}
+
+// Local is the Remote view of the entries of this store, without pulling from its own remote
+func (s *Store) Local() Remote {
+	return local{s}
+}
+
+type local struct {
+	s *Store
+}
+
+func (l local) GetCode(addr common.Address) []byte {
+	l.s.mu.RLock()
+	defer l.s.mu.RUnlock()
+	return copyOf(l.s.codes[addr])
+}
+
+func (l local) GetStates(addr common.Address, hash []byte) []byte {
+	l.s.mu.RLock()
+	defer l.s.mu.RUnlock()
+	return copyOf(l.s.states[addr][string(hash)])
+}
+
+func (l local) GetInfo(addr common.Address, hash []byte) []byte {
+	l.s.mu.RLock()
+	defer l.s.mu.RUnlock()
+	return copyOf(l.s.info[addr][string(hash)])
+}
+
+// return a copy to prevent modification from outside
+func copyOf(b []byte) []byte {
+	if b == nil {
+		return nil
 	}
+	return append([]byte{}, b...)
 }
 
-func GetStates(addr common.Address, hash []byte) []byte {
-	if state, exists := states[addr][string(hash)]; exists {
-		// return a copy of the state to prevent modification from outside
-		return append([]byte{}, state...)
+func (s *Store) GetCode(addr common.Address) []byte {
+	if code := s.Local().GetCode(addr); code != nil {
+		return code
+	}
+	if s.remote == nil {
+		return nil
 	}
-	return nil
+	// the code of a program deployed by a rival
+	code := s.remote.GetCode(addr)
+	if code != nil {
+		s.SetCode(addr, copyOf(code))
+	}
+	return code
 }
 
-func SetStates(addr common.Address, hash []byte, state []byte) {
-	if states[addr] == nil {
-		states[addr] = map[string][]byte{}
+func (s *Store) SetCode(addr common.Address, code []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.codes[addr] == nil {
+		s.codes[addr] = code
+		s.journal = append(s.journal, journalEntry{addr: addr})
 	}
-	states[addr][string(hash)] = state
 }
 
-func GetInfo(addr common.Address, hash []byte) []byte {
-	if i, exists := info[addr][string(hash)]; exists {
-		// return a copy of the list to prevent modification from outside
-		return append([]byte{}, i...)
+func (s *Store) GetStates(addr common.Address, hash []byte) []byte {
+	if state := s.Local().GetStates(addr, hash); state != nil {
+		return state
+	}
+	if s.remote == nil {
+		return nil
+	}
+	// the states output by a rival
+	state := s.remote.GetStates(addr, hash)
+	if state != nil {
+		s.SetStates(addr, hash, copyOf(state))
 	}
-	return nil
+	return state
 }
 
-func SetInfo(addr common.Address, hash []byte, i []byte) {
-	if info[addr] == nil {
-		info[addr] = map[string][]byte{}
+func (s *Store) SetStates(addr common.Address, hash []byte, state []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.set(s.states, addr, hash, state)
+}
+
+func (s *Store) GetInfo(addr common.Address, hash []byte) []byte {
+	if i := s.Local().GetInfo(addr, hash); i != nil {
+		return i
+	}
+	if s.remote == nil {
+		return nil
+	}
+	// the info output by a rival
+	i := s.remote.GetInfo(addr, hash)
+	if i != nil {
+		s.SetInfo(addr, hash, copyOf(i))
+	}
+	return i
+}
+
+func (s *Store) SetInfo(addr common.Address, hash []byte, i []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.set(s.info, addr, hash, i)
+}
+
+func (s *Store) set(store map[common.Address]map[string][]byte, addr common.Address, hash []byte, value []byte) {
+	if store[addr] == nil {
+		store[addr] = map[string][]byte{}
+	}
+	if _, exists := store[addr][string(hash)]; !exists {
+		s.journal = append(s.journal, journalEntry{store: store, addr: addr, hash: string(hash)})
+	}
+	store[addr][string(hash)] = value
+}
+
+// Snapshot returns an identifier for the current set of writes
+func (s *Store) Snapshot() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.snapshot()
+}
+
+func (s *Store) snapshot() int {
+	return s.journalBase + len(s.journal)
+}
+
+// RevertToSnapshot discards every write made after the given snapshot
+func (s *Store) RevertToSnapshot(id int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if id < s.journalBase {
+		id = s.journalBase
+	}
+	if id > s.snapshot() {
+		return
+	}
+	for i := len(s.journal) - 1; i >= id-s.journalBase; i-- {
+		e := s.journal[i]
+		if e.store == nil {
+			delete(s.codes, e.addr)
+		} else {
+			delete(e.store[e.addr], e.hash)
+		}
+	}
+	s.journal = s.journal[:id-s.journalBase]
+}
+
+// Finalize forgets the journal before the given snapshot, those writes can no longer be reverted
+func (s *Store) Finalize(id int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if id <= s.journalBase {
+		return
+	}
+	if id > s.snapshot() {
+		id = s.snapshot()
 	}
-	info[addr][string(hash)] = i
+	s.journal = append([]journalEntry{}, s.journal[id-s.journalBase:]...)
+	s.journalBase = id
 }
@@ -10,9 +10,9 @@ import (
 	"github.com/ethereum/go-ethereum/core/types"
 )
 
-func CallRegister(attestationReport, key []byte, depositAmount *big.Int, account help.Account) error {
-	client := help.Client
-	parsedABI := help.ParsedMCABI
+func CallRegister(chain *help.Chain, attestationReport, key []byte, depositAmount *big.Int, account help.Account) error {
+	client := chain.Client
+	parsedABI := chain.ParsedMCABI
 
 	// prepare register call data
 	callData, err := parsedABI.Pack("register", attestationReport, key)
@@ -29,10 +29,10 @@ func CallRegister(attestationReport, key []byte, depositAmount *big.Int, account
 	if err != nil {
 		return fmt.Errorf("failed to get gas price: %v", err)
 	}
-	tx := types.NewTransaction(nonce, common.HexToAddress(help.MCAddress), depositAmount, 5000000, gasPrice, callData)
+	tx := types.NewTransaction(nonce, common.HexToAddress(chain.MCAddress), depositAmount, 5000000, gasPrice, callData)
 
 	// sign transaction
-	signedTx, err := help.SignTransaction(client, account.PrivateKey, tx)
+	signedTx, err := chain.SignTransaction(account.PrivateKey, tx)
 	if err != nil {
 		return fmt.Errorf("failed to sign transaction: %v", err)
 	}
@@ -47,9 +47,9 @@ func CallRegister(attestationReport, key []byte, depositAmount *big.Int, account
 	return nil
 }
 
-func CallWithdraw(signature []byte, account help.Account) error {
-	client := help.Client
-	parsedABI := help.ParsedMCABI
+func CallWithdraw(chain *help.Chain, signature []byte, account help.Account) error {
+	client := chain.Client
+	parsedABI := chain.ParsedMCABI
 
 	// create withdraw call data
 	callData, err := parsedABI.Pack("withdraw", signature)
@@ -66,10 +66,10 @@ func CallWithdraw(signature []byte, account help.Account) error {
 	if err != nil {
 		return fmt.Errorf("failed to get gas price: %v", err)
 	}
-	tx := types.NewTransaction(nonce, common.HexToAddress(help.MCAddress), big.NewInt(0), 3000000, gasPrice, callData)
+	tx := types.NewTransaction(nonce, common.HexToAddress(chain.MCAddress), big.NewInt(0), 3000000, gasPrice, callData)
 
 	// sign transaction
-	signedTx, err := help.SignTransaction(client, account.PrivateKey, tx)
+	signedTx, err := chain.SignTransaction(account.PrivateKey, tx)
 	if err != nil {
 		return fmt.Errorf("failed to sign transaction: %v", err)
 	}
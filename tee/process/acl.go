@@ -0,0 +1,147 @@
+package process
+
+import (
+	"encoding/hex"
+	"fmt"
+	"tee/help"
+	"tee/key"
+	"tee/node"
+	pb "tee/proto"
+	"tee/pull"
+	"tee/utils"
+
+	"github.com/ethereum/go-ethereum/common"
+	"golang.org/x/exp/rand"
+	"google.golang.org/protobuf/proto"
+)
+
+func ChangeACL(n *node.Node, event map[string]interface{}) ([]help.Output, error) {
+	data, err := utils.Field[map[string]interface{}](event, "data")
+	if err != nil {
+		return nil, fail("Malformed event", err)
+	}
+	programAddress, err := utils.Field[common.Address](data, "programAddress")
+	if err != nil {
+		return nil, fail("Malformed event", err)
+	}
+	callerAddress, err := utils.Field[common.Address](data, "caller")
+	if err != nil {
+		return nil, fail("Malformed event", err)
+	}
+	caller := callerAddress.String()
+	encryptedInput, err := utils.Field[[]byte](data, "encryptedInput")
+	if err != nil {
+		return nil, fail("Malformed event", err)
+	}
+	pubKey, err := utils.Field[[]byte](data, "transactionKey")
+	if err != nil {
+		return nil, fail("Malformed event", err)
+	}
+
+	// decrypt and decode the ACL input
+	inputBytes, err := n.Keys.ECIESDecrypt(encryptedInput, hex.EncodeToString(pubKey))
+	if err != nil {
+		return nil, fail("Failed to decrypt ACL input", err)
+	}
+	var input pb.ACLInput
+	err = proto.Unmarshal(inputBytes, &input)
+	if err != nil {
+		return nil, fail("Failed to unmarshal ACL input", err)
+	}
+
+	// get program info
+	info, err := pull.GetProgramInfo(n, programAddress)
+	if err != nil {
+		return nil, fail("Failed to get program info", err)
+	}
+
+	err = checkDeployer(info, caller, programAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	// apply the operation
+	acl, err := applyACL(info.ACL, &input)
+	if err != nil {
+		return nil, fail("Failed to change ACL", err)
+	}
+	info.ACL = acl
+	info.Nounce = uint32(rand.Intn(1000000)) // set nounce to a random number
+
+	// encrypt info
+	infoBytes, err := proto.Marshal(info)
+	if err != nil {
+		return nil, fail("Failed to encode info", err)
+	}
+	encryptedInfo, err := key.EncryptAES(infoBytes, n.Keys.KeyMgt)
+	if err != nil {
+		return nil, fail("Failed to encrypt info", err)
+	}
+
+	// save info off-chain
+	infoHash := key.GetHash(encryptedInfo)
+	n.OCS.SetInfo(programAddress, infoHash, encryptedInfo)
+
+	// prepare output
+	output := help.Output{
+		TransType:      help.TransTypeACL,
+		ProgramAddress: programAddress,
+		Info:           help.ByteToByte32(infoHash),
+	}
+
+	// save info to cache
+	n.Cache.SetProgramInfo(programAddress, info)
+	return []help.Output{output}, nil
+}
+
+// only the deployer can change the ACL, programs deployed before the deployer was recorded keep their ACL
+func checkDeployer(info *pb.Info, caller string, programAddress common.Address) error {
+	if info.Deployer == "" {
+		return fail("Program has no recorded deployer", fmt.Errorf("the ACL of %v can not be changed", programAddress.Hex()))
+	}
+	if info.Deployer != caller {
+		return fail("Caller is not the deployer", fmt.Errorf("caller %v is not the deployer of %v", caller, programAddress.Hex()))
+	}
+	return nil
+}
+
+// apply add/remove/replace to the current ACL, an empty ACL means anyone can execute the program.
+// a remove can not empty the ACL, opening the program to anyone takes an explicit replace with no address.
+func applyACL(acl []string, input *pb.ACLInput) ([]string, error) {
+	// normalize addresses so that they match the checksummed caller
+	addrs := []string{}
+	for _, a := range input.Addresses {
+		if !common.IsHexAddress(a) {
+			return nil, fmt.Errorf("invalid address: %v", a)
+		}
+		addr := common.HexToAddress(a).String()
+		if !utils.Contains(addrs, addr) {
+			addrs = append(addrs, addr)
+		}
+	}
+
+	switch input.Op {
+	case pb.ACLOperation_Add:
+		newACL := append([]string{}, acl...)
+		for _, addr := range addrs {
+			if !utils.Contains(newACL, addr) {
+				newACL = append(newACL, addr)
+			}
+		}
+		return newACL, nil
+	case pb.ACLOperation_Remove:
+		newACL := []string{}
+		for _, addr := range acl {
+			if !utils.Contains(addrs, addr) {
+				newACL = append(newACL, addr)
+			}
+		}
+		if len(acl) > 0 && len(newACL) == 0 {
+			return nil, fmt.Errorf("removing every address makes the program public, use replace instead")
+		}
+		return newACL, nil
+	case pb.ACLOperation_Replace:
+		return addrs, nil
+	}
+	return nil, fmt.Errorf("unknown ACL operation: %v", input.Op)
+}
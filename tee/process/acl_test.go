@@ -0,0 +1,87 @@
+package process
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	pb "tee/proto"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+var (
+	aclA = common.HexToAddress("0x0a").String()
+	aclB = common.HexToAddress("0x0b").String()
+	aclC = common.HexToAddress("0x0c").String()
+)
+
+func TestApplyACL(t *testing.T) {
+	tests := []struct {
+		name    string
+		acl     []string
+		op      pb.ACLOperation
+		addrs   []string
+		want    []string
+		wantErr bool
+	}{
+		{name: "add", acl: []string{aclA}, op: pb.ACLOperation_Add, addrs: []string{aclB}, want: []string{aclA, aclB}},
+		{name: "add existing", acl: []string{aclA}, op: pb.ACLOperation_Add, addrs: []string{aclA, aclB, aclB}, want: []string{aclA, aclB}},
+		// addresses are checksummed to match the caller
+		{name: "add lowercase", acl: nil, op: pb.ACLOperation_Add, addrs: []string{"0x000000000000000000000000000000000000000a"}, want: []string{aclA}},
+		{name: "add invalid", acl: nil, op: pb.ACLOperation_Add, addrs: []string{"0x0a"}, wantErr: true},
+		{name: "remove", acl: []string{aclA, aclB, aclC}, op: pb.ACLOperation_Remove, addrs: []string{aclB}, want: []string{aclA, aclC}},
+		{name: "remove missing", acl: []string{aclA}, op: pb.ACLOperation_Remove, addrs: []string{aclB}, want: []string{aclA}},
+		// an empty ACL opens the program to anyone
+		{name: "remove all", acl: []string{aclA, aclB}, op: pb.ACLOperation_Remove, addrs: []string{aclA, aclB}, wantErr: true},
+		{name: "replace", acl: []string{aclA}, op: pb.ACLOperation_Replace, addrs: []string{aclB, aclC}, want: []string{aclB, aclC}},
+		{name: "replace empty", acl: []string{aclA}, op: pb.ACLOperation_Replace, addrs: nil, want: []string{}},
+		{name: "unknown operation", acl: []string{aclA}, op: pb.ACLOperation(99), addrs: []string{aclB}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := applyACL(tt.acl, &pb.ACLInput{Op: tt.op, Addresses: tt.addrs})
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("applyACL returned %v, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("applyACL failed: %v", err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("applyACL returned %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckDeployer(t *testing.T) {
+	program := common.HexToAddress("0x01")
+	tests := []struct {
+		name     string
+		deployer string
+		caller   string
+		wantMsg  string
+	}{
+		{name: "deployer", deployer: aclA, caller: aclA},
+		{name: "other caller", deployer: aclA, caller: aclB, wantMsg: "Caller is not the deployer"},
+		{name: "no deployer", deployer: "", caller: aclA, wantMsg: "Program has no recorded deployer"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkDeployer(&pb.Info{Deployer: tt.deployer}, tt.caller, program)
+			if tt.wantMsg == "" {
+				if err != nil {
+					t.Fatalf("checkDeployer failed: %v", err)
+				}
+				return
+			}
+			var f *Failure
+			if !errors.As(err, &f) || f.Msg != tt.wantMsg {
+				t.Errorf("checkDeployer returned %v, want %q", err, tt.wantMsg)
+			}
+		})
+	}
+}
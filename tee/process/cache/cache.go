@@ -4,6 +4,7 @@ import (
 	pb "tee/proto"
 
 	"github.com/ethereum/go-ethereum/common"
+	"google.golang.org/protobuf/proto"
 )
 
 type PRGCache struct {
@@ -22,38 +23,65 @@ type PRGCache struct {
 // }
 
 // store the code and states of all program within one round of execution
-var CacheStates = make(map[common.Address]PRGCache)
-var CacheInfos = make(map[common.Address]*pb.Info)
+type Cache struct {
+	States map[common.Address]PRGCache
+	Infos  map[common.Address]*pb.Info
+}
 
-func GetProgramDetails(programAddress common.Address) ([]byte, []byte) {
-	if cache, ok := CacheStates[programAddress]; ok {
+func New() *Cache {
+	return &Cache{
+		States: make(map[common.Address]PRGCache),
+		Infos:  make(map[common.Address]*pb.Info),
+	}
+}
+
+func (c *Cache) GetProgramDetails(programAddress common.Address) ([]byte, []byte) {
+	if cache, ok := c.States[programAddress]; ok {
 		return cache.Code, cache.States
 	}
 	return nil, nil
 }
 
-func GetProgramInfo(programAddress common.Address) *pb.Info {
-	if cache, ok := CacheInfos[programAddress]; ok {
+func (c *Cache) GetProgramInfo(programAddress common.Address) *pb.Info {
+	if cache, ok := c.Infos[programAddress]; ok {
 		return cache
 	}
 	return nil
 }
 
-func SetBatchProgramDetails(addrs []common.Address, codes [][]byte, allStates [][]byte) {
+func (c *Cache) SetBatchProgramDetails(addrs []common.Address, codes [][]byte, allStates [][]byte) {
 	for i, addr := range addrs {
-		SetProgramDetails(addr, codes[i], allStates[i])
+		c.SetProgramDetails(addr, codes[i], allStates[i])
 	}
 }
 
-func SetProgramDetails(programAddress common.Address, code []byte, states []byte) {
-	CacheStates[programAddress] = PRGCache{Code: code, States: states}
+func (c *Cache) SetProgramDetails(programAddress common.Address, code []byte, states []byte) {
+	c.States[programAddress] = PRGCache{Code: code, States: states}
+}
+
+func (c *Cache) SetProgramInfo(programAddress common.Address, info *pb.Info) {
+	c.Infos[programAddress] = info
+}
+
+func (c *Cache) ClearCache() {
+	c.States = make(map[common.Address]PRGCache)
+	c.Infos = make(map[common.Address]*pb.Info)
 }
 
-func SetProgramInfo(programAddress common.Address, info *pb.Info) {
-	CacheInfos[programAddress] = info
+// Copy returns a deep copy of the cache, used to discard the changes of a failed event
+func (c *Cache) Copy() *Cache {
+	cp := New()
+	for addr, s := range c.States {
+		cp.States[addr] = s
+	}
+	for addr, info := range c.Infos {
+		cp.Infos[addr] = proto.Clone(info).(*pb.Info)
+	}
+	return cp
 }
 
-func ClearCache() {
-	CacheStates = make(map[common.Address]PRGCache)
-	CacheInfos = make(map[common.Address]*pb.Info)
+// Restore replaces the content of the cache with a copy
+func (c *Cache) Restore(cp *Cache) {
+	c.States = cp.States
+	c.Infos = cp.Infos
 }
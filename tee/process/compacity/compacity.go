@@ -1,11 +1,15 @@
 package compacity
 
 import (
+	"fmt"
 	"math/big"
-	"tee/help"
+	"slices"
 	"tee/process/evm"
 	"tee/process/golang"
+	"tee/process/golang/vm"
+	"tee/process/wasm"
 	pb "tee/proto"
+	"tee/utils"
 
 	"github.com/ethereum/go-ethereum/common"
 	"google.golang.org/protobuf/proto"
@@ -14,99 +18,226 @@ import (
 type Config struct {
 	ProgramAddress common.Address
 	Caller         common.Address
-	BlockNumber    *big.Int
+	BlockNumber    *big.Int // block of the event, the EVM raises it to select its rules, see evm.SetConfig
 	BlockTime      uint64
-}
-
-var VMGolang = "golang"
-var VMSolidity = "solidity"
-
-func vm() string {
-	if help.Lang == "g" {
-		return VMGolang
-	}
-	return VMSolidity
+	VM             pb.VMType     // set from the user config at deploy time and from the program info afterwards
+	Seed           int64         // seed of the random source of golang programs
+	Limits         vm.Limits     // time and memory budget of golang programs, the memory also limits wasm programs
+	GasLimit       uint64        // gas of the execution in the EVM or fuel of a wasm program, 0 for the default
+	Loader         evm.Loader    // loads the programs interacting with a solidity program
+	GolangLoader   golang.Loader // loads the programs called by a golang program
+	Pool           *vm.Pool      // interpreters of golang programs reused between executions, nil for none
 }
 
 func Deploy(code []byte, conf Config) ([]byte, []byte, error) {
-	vm := vm()
-	var states []byte
-	var newCode []byte
-	var err error
-	switch vm {
-	case VMGolang:
-		states, newCode, err = deployGolang(code)
-	case VMSolidity:
-		states, newCode, err = deploySolidity(code, conf)
+	switch conf.VM {
+	case pb.VMType_Golang:
+		return deployGolang(code, conf)
+	case pb.VMType_Solidity:
+		return deploySolidity(code, conf)
+	case pb.VMType_Wasm:
+		return deployWasm(code, conf)
 	}
-	return states, newCode, err
+	return nil, nil, fmt.Errorf("unknown VM type: %v", conf.VM)
 }
 
-func Execute(code []byte, states []byte, input []byte, conf Config) ([]common.Address, [][]byte, [][]byte, interface{}, error) {
-	vm := vm()
-	var addresses []common.Address
-	var newStates [][]byte
-	var codes [][]byte
-	var result interface{}
-	var err error
-	switch vm {
-	case VMGolang:
-		addresses, newStates, codes, result, err = executeGolang(code, states, input, conf)
-	case VMSolidity:
-		addresses, newStates, codes, result, err = executeSolidity(code, states, input, conf)
+// Execute runs a program, it returns the programs loaded with their states and codes, the result and the gas used in the EVM
+func Execute(code []byte, states []byte, input []byte, conf Config) ([]common.Address, [][]byte, [][]byte, interface{}, uint64, error) {
+	switch conf.VM {
+	case pb.VMType_Golang:
+		return executeGolang(code, states, input, conf)
+	case pb.VMType_Solidity:
+		return executeSolidity(code, states, input, conf)
+	case pb.VMType_Wasm:
+		return executeWasm(code, states, input, conf)
 	}
-	return addresses, newStates, codes, result, err
+	return nil, nil, nil, nil, 0, fmt.Errorf("unknown VM type: %v", conf.VM)
 }
 
 func deploySolidity(code []byte, conf Config) ([]byte, []byte, error) {
-	evm.SetConfig(conf.BlockNumber, conf.BlockTime, conf.ProgramAddress, conf.Caller)
-	states, newCode, err := evm.Deploy(code)
+	engine := evm.New(conf.Loader)
+	engine.SetConfig(conf.BlockNumber, conf.BlockTime, conf.ProgramAddress, conf.Caller)
+	engine.SetGasLimit(conf.GasLimit)
+	states, newCode, err := engine.Deploy(code)
 	return states, newCode, err
 }
 
-func deployGolang(code []byte) ([]byte, []byte, error) {
-	states, err := golang.Deploy(code)
+func deployGolang(code []byte, conf Config) ([]byte, []byte, error) {
+	states, err := golang.Deploy(code, golangContext(conf), conf.GolangLoader, conf.Pool)
 	return states, code, err
 }
 
-func executeGolang(code []byte, states []byte, input []byte, conf Config) ([]common.Address, [][]byte, [][]byte, interface{}, error) {
+func executeGolang(code []byte, states []byte, input []byte, conf Config) ([]common.Address, [][]byte, [][]byte, interface{}, uint64, error) {
 	// parse input
 	var decodedInput pb.GolangInput
 	err := proto.Unmarshal(input, &decodedInput)
 	if err != nil {
-		return nil, nil, nil, nil, err
+		return nil, nil, nil, nil, 0, err
 	}
 	// execute the program
-	newStates, result, err := golang.Execute(code, states, decodedInput.FuncName, string(decodedInput.Args))
-	addresses := []common.Address{conf.ProgramAddress}
-	resStates := [][]byte{newStates}
-	codes := [][]byte{code}
-	return addresses, resStates, codes, result, err
+	b := &bridge{conf: conf}
+	defer b.close()
+	result, err := b.golang().Execute(code, states, decodedInput.FuncName, decodedInput.Args)
+	if err != nil {
+		return nil, nil, nil, nil, b.gasUsed(), err
+	}
+	addresses, resStates, codes, err := b.loaded()
+	return addresses, resStates, codes, result, b.gasUsed(), err
 }
 
-func executeSolidity(code []byte, states []byte, input []byte, conf Config) ([]common.Address, [][]byte, [][]byte, interface{}, error) {
-	evm.SetConfig(conf.BlockNumber, conf.BlockTime, conf.ProgramAddress, conf.Caller)
-	// newStates, result, err := evm.Execute(code, states, input)
-	return evm.Execute(code, states, input)
+// the code and states of a solidity program are not used, the loader supplies them when the call reaches the program
+func executeSolidity(_ []byte, _ []byte, input []byte, conf Config) ([]common.Address, [][]byte, [][]byte, interface{}, uint64, error) {
+	b := &bridge{conf: conf}
+	defer b.close()
+	result, err := b.engine().Call(conf.Caller, conf.ProgramAddress, input)
+	if b.session != nil && b.session.Err() != nil {
+		// the failed call of a golang program explains the failure of the solidity program
+		err = b.session.Err()
+	}
+	if err != nil {
+		fmt.Println("Error executing contract:", err)
+		return nil, nil, nil, nil, b.gasUsed(), err
+	}
+	addresses, resStates, codes, err := b.loaded()
+	return addresses, resStates, codes, result, b.gasUsed(), err
 }
 
-func GetCompacityConfig(event map[string]interface{}) Config {
-	data := event["data"].(map[string]interface{})
+// bridge runs the solidity and golang programs of one execution, they call each other through it.
+// each engine is created on first use.
+type bridge struct {
+	conf    Config
+	evm     *evm.Engine
+	session *golang.Session
+}
+
+func (b *bridge) engine() *evm.Engine {
+	if b.evm == nil {
+		b.evm = evm.New(b.conf.Loader)
+		b.evm.SetConfig(b.conf.BlockNumber, b.conf.BlockTime, b.conf.ProgramAddress, b.conf.Caller)
+		b.evm.SetGasLimit(b.conf.GasLimit)
+		b.evm.SetGolangCaller(b.golang().Call)
+	}
+	return b.evm
+}
+
+func (b *bridge) golang() *golang.Session {
+	if b.session == nil {
+		b.session = golang.NewSession(golangContext(b.conf), b.conf.GolangLoader, func(from common.Address, to common.Address, input []byte) ([]byte, error) {
+			return b.engine().Call(from, to, input)
+		}, b.conf.Pool)
+	}
+	return b.session
+}
+
+// close returns the interpreters of the golang programs to the pool
+func (b *bridge) close() {
+	if b.session != nil {
+		b.session.Close()
+	}
+}
+
+// gas used in the EVM, golang programs only use gas through their calls to solidity programs
+func (b *bridge) gasUsed() uint64 {
+	if b.evm == nil {
+		return 0
+	}
+	return b.evm.GasUsed()
+}
+
+// loaded merges the programs loaded by both engines, the program of the execution comes first
+func (b *bridge) loaded() ([]common.Address, [][]byte, [][]byte, error) {
+	var addresses []common.Address
+	var states, codes [][]byte
+	// a failed call between programs fails the execution even if the caller ignored it
+	if b.session != nil && b.session.Err() != nil {
+		return nil, nil, nil, b.session.Err()
+	}
+	engines := []func() ([]common.Address, [][]byte, [][]byte, error){}
+	if b.session != nil {
+		engines = append(engines, b.session.Loaded)
+	}
+	if b.evm != nil {
+		engines = append(engines, b.evm.Loaded)
+	}
+	if b.conf.VM == pb.VMType_Solidity {
+		slices.Reverse(engines)
+	}
+	// each program has one output, whose states hash would conflict with a second one
+	seen := map[common.Address]bool{}
+	for _, loaded := range engines {
+		a, s, c, err := loaded()
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		for _, addr := range a {
+			if seen[addr] {
+				return nil, nil, nil, fmt.Errorf("program %v loaded twice", addr.Hex())
+			}
+			seen[addr] = true
+		}
+		addresses = append(addresses, a...)
+		states = append(states, s...)
+		codes = append(codes, c...)
+	}
+	return addresses, states, codes, nil
+}
+
+// the fuel of a wasm program is its gas, its memory is limited like the memory of a golang program
+func deployWasm(code []byte, conf Config) ([]byte, []byte, error) {
+	engine := wasm.New(conf.GasLimit, conf.Limits.Memory)
+	engine.SetConfig(conf.BlockNumber, conf.BlockTime, conf.ProgramAddress, conf.Caller)
+	states, err := engine.Deploy(code)
+	return states, code, err
+}
+
+func executeWasm(code []byte, states []byte, input []byte, conf Config) ([]common.Address, [][]byte, [][]byte, interface{}, uint64, error) {
+	engine := wasm.New(conf.GasLimit, conf.Limits.Memory)
+	engine.SetConfig(conf.BlockNumber, conf.BlockTime, conf.ProgramAddress, conf.Caller)
+	newStates, result, err := engine.Execute(code, states, input)
+	if err != nil {
+		return nil, nil, nil, nil, engine.FuelUsed(), err
+	}
+	return []common.Address{conf.ProgramAddress}, [][]byte{newStates}, [][]byte{code}, result, engine.FuelUsed(), nil
+}
+
+func golangContext(conf Config) vm.Context {
+	return vm.Context{
+		Caller:         conf.Caller,
+		ProgramAddress: conf.ProgramAddress,
+		BlockNumber:    conf.BlockNumber.Uint64(),
+		BlockTime:      conf.BlockTime,
+		Seed:           conf.Seed,
+		Limits:         conf.Limits,
+	}
+}
+
+func GetCompacityConfig(event map[string]interface{}) (Config, error) {
+	data, err := utils.Field[map[string]interface{}](event, "data")
+	if err != nil {
+		return Config{}, err
+	}
 	// get program info, prepare for deploy
-	programAddress := data["programAddress"].(common.Address)
-	callerAddress := data["caller"].(common.Address)
-	blockNumber := event["blockNumber"].(*big.Int)
-	// set block number
-	// less than 12965000, set block number to 12965000, since lower block number has some unexpected behavior
-	if blockNumber.Cmp(big.NewInt(12965000)) < 0 {
-		blockNumber = big.NewInt(12965000)
-	}
-	blockTime := event["blockTime"].(uint64)
+	programAddress, err := utils.Field[common.Address](data, "programAddress")
+	if err != nil {
+		return Config{}, err
+	}
+	callerAddress, err := utils.Field[common.Address](data, "caller")
+	if err != nil {
+		return Config{}, err
+	}
+	blockNumber, err := utils.Field[*big.Int](event, "blockNumber")
+	if err != nil {
+		return Config{}, err
+	}
+	blockTime, err := utils.Field[uint64](event, "blockTime")
+	if err != nil {
+		return Config{}, err
+	}
 	conf := Config{
 		ProgramAddress: programAddress,
 		Caller:         callerAddress,
 		BlockNumber:    blockNumber,
 		BlockTime:      blockTime,
 	}
-	return conf
+	return conf, nil
 }
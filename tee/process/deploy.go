@@ -2,61 +2,100 @@ package process
 
 import (
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"tee/help"
 	"tee/key"
-	"tee/ocs"
-	"tee/process/cache"
+	"tee/node"
 	"tee/process/compacity"
+	"tee/process/golang/vm"
 	pb "tee/proto"
+	"tee/utils"
+	"time"
 
 	"github.com/ethereum/go-ethereum/common"
 	"golang.org/x/exp/rand"
 	"google.golang.org/protobuf/proto"
 )
 
-func Deploy(event map[string]interface{}) []help.Output {
-	data := event["data"].(map[string]interface{})
-
-	// deploy program
-	conf := compacity.GetCompacityConfig(event)
-	encryptedCode := data["encryptedCode"].([]byte)
-	pubKey := data["transactionKey"].([]byte)
-	code, err := key.ECIESDecrypt(encryptedCode, hex.EncodeToString(pubKey))
-
+func Deploy(n *node.Node, event map[string]interface{}) ([]help.Output, error) {
+	data, err := utils.Field[map[string]interface{}](event, "data")
+	if err != nil {
+		return nil, fail("Malformed event", err)
+	}
+	programAddress, err := utils.Field[common.Address](data, "programAddress")
+	if err != nil {
+		return nil, fail("Malformed event", err)
+	}
+	encryptedCode, err := utils.Field[[]byte](data, "encryptedCode")
+	if err != nil {
+		return nil, fail("Malformed event", err)
+	}
+	pubKey, err := utils.Field[[]byte](data, "transactionKey")
 	if err != nil {
-		fmt.Printf("Failed to execute decrypt code: %v", err)
-		return []help.Output{}
+		return nil, fail("Malformed event", err)
+	}
+	encryptedConfig, err := utils.Field[[]byte](data, "encryptedConfig")
+	if err != nil {
+		return nil, fail("Malformed event", err)
 	}
 
-	states, newCode, err := compacity.Deploy(code, conf)
+	// deploy program
+	conf, err := compacity.GetCompacityConfig(event)
 	if err != nil {
-		fmt.Printf("Failed to deploy program: %v", err)
-		return []help.Output{}
+		return nil, fail("Malformed event", err)
 	}
 
 	// get user config
-	encryptedConfig := data["encryptedConfig"].([]byte)
-	configBytes, err := key.ECIESDecrypt(encryptedConfig, hex.EncodeToString(pubKey))
+	configBytes, err := n.Keys.ECIESDecrypt(encryptedConfig, hex.EncodeToString(pubKey))
 	if err != nil {
-		fmt.Printf("Failed to decrypt config: %v", err)
-		return []help.Output{}
+		return nil, fail("Failed to decrypt config", err)
 	}
 	var userConfig pb.UserConfig
 	err = proto.Unmarshal(configBytes, &userConfig)
 	if err != nil {
-		fmt.Printf("Failed to unmarshal userConfig: %v", err)
-		return []help.Output{}
+		return nil, fail("Failed to unmarshal config", err)
+	}
+	conf.VM = userConfig.VM
+	conf.Seed = seed(n, event, programAddress)
+	if n.MaxTimeout > 0 && time.Duration(userConfig.TimeoutMs)*time.Millisecond > n.MaxTimeout {
+		return nil, fail("Timeout above the TEE limit", fmt.Errorf("timeout %vms above %v", userConfig.TimeoutMs, n.MaxTimeout))
+	}
+	if n.MaxMemoryMB > 0 && userConfig.MemoryMB > n.MaxMemoryMB {
+		return nil, fail("Memory above the TEE limit", fmt.Errorf("memory %vMB above %vMB", userConfig.MemoryMB, n.MaxMemoryMB))
+	}
+	if n.MaxGas > 0 && userConfig.GasLimit > n.MaxGas {
+		return nil, fail("Gas limit above the TEE limit", fmt.Errorf("gas %v above %v", userConfig.GasLimit, n.MaxGas))
+	}
+	conf.Limits = limits(n, userConfig.TimeoutMs, userConfig.MemoryMB)
+	conf.GasLimit = gasLimit(n, userConfig.GasLimit)
+	// the programs declared by a golang program are checked for cycles
+	conf.GolangLoader = loader(n, pb.VMType_Golang)
+	conf.Pool = n.Programs
+
+	code, err := n.Keys.ECIESDecrypt(encryptedCode, hex.EncodeToString(pubKey))
+	if err != nil {
+		return nil, fail("Failed to decrypt code", err)
+	}
+
+	states, newCode, err := compacity.Deploy(code, conf)
+	if err != nil {
+		// the deployer gets the file and line of an error of its code, only in the result encrypted with its result key
+		var codeErr *vm.CodeError
+		if errors.As(err, &codeErr) {
+			return nil, &Failure{Msg: "Failed to deploy program", Err: err, Detail: codeErr.Error()}
+		}
+		return nil, fail("Failed to deploy program", err)
 	}
 
 	// set info field
 	stateKey, err := key.GenerateAESKey()
 	if err != nil {
-		panic(fmt.Sprintf("Failed to generate state key: %v", err))
+		return nil, fail("Failed to generate state key", err)
 	}
 	codeKey, err := key.GenerateAESKey()
 	if err != nil {
-		panic(fmt.Sprintf("Failed to generate code key: %v", err))
+		return nil, fail("Failed to generate code key", err)
 	}
 	info := &pb.Info{
 		Keys:              []string{stateKey},
@@ -67,33 +106,39 @@ func Deploy(event map[string]interface{}) []help.Output {
 		ExecutionCount:    0,
 		// random Nounce for each prevent leakages
 		Nounce: uint32(rand.Intn(1000000)),
+		// only the deployer can change the ACL later
+		Deployer: conf.Caller.String(),
+		VM:       userConfig.VM,
+		// limits of every execution
+		TimeoutMs: userConfig.TimeoutMs,
+		MemoryMB:  userConfig.MemoryMB,
+		GasLimit:  userConfig.GasLimit,
 	}
 	infoBytes, err := proto.Marshal(info)
 	if err != nil {
-		panic(fmt.Sprintf("Failed to encode info: %v", err))
+		return nil, fail("Failed to encode info", err)
 	}
 
 	// prepare output
-	encryptedInfo, err := key.EncryptAES(infoBytes, key.KeyMgt)
+	encryptedInfo, err := key.EncryptAES(infoBytes, n.Keys.KeyMgt)
 	if err != nil {
-		panic(fmt.Sprintf("Failed to encrypt info: %v", err))
+		return nil, fail("Failed to encrypt info", err)
 	}
 	encryptedStates, err := key.EncryptAES([]byte(states), stateKey)
 	if err != nil {
-		panic(fmt.Sprintf("Failed to encrypt states: %v", err))
+		return nil, fail("Failed to encrypt states", err)
 	}
 	newEncryptedCode, err := key.EncryptAES(newCode, codeKey)
 	if err != nil {
-		panic(fmt.Sprintf("Failed to encrypt code: %v", err))
+		return nil, fail("Failed to encrypt code", err)
 	}
-	programAddress := data["programAddress"].(common.Address)
 	// store code and states off-chain
 	codeHash := key.GetHash(newEncryptedCode)
 	statesHash := key.GetHash(encryptedStates)
 	infoHash := key.GetHash(encryptedInfo)
-	ocs.SetCode(programAddress, newEncryptedCode)
-	ocs.SetStates(programAddress, statesHash, encryptedStates)
-	ocs.SetInfo(programAddress, infoHash, encryptedInfo)
+	n.OCS.SetCode(programAddress, newEncryptedCode)
+	n.OCS.SetStates(programAddress, statesHash, encryptedStates)
+	n.OCS.SetInfo(programAddress, infoHash, encryptedInfo)
 	// prepare output
 	output := help.Output{
 		TransType:      help.TransTypeDeploy,
@@ -106,8 +151,8 @@ func Deploy(event map[string]interface{}) []help.Output {
 	}
 
 	// save new states to cache
-	cache.SetProgramDetails(programAddress, newCode, states)
+	n.Cache.SetProgramDetails(programAddress, newCode, states)
 	// save info to cache
-	cache.SetProgramInfo(programAddress, info)
-	return []help.Output{output}
+	n.Cache.SetProgramInfo(programAddress, info)
+	return []help.Output{output}, nil
 }
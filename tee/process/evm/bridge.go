@@ -0,0 +1,123 @@
+package evm
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/vm"
+)
+
+// GolangBridgeAddress is the precompile through which solidity programs call golang programs, see GolangBridge.sol:
+//
+//	interface IGolangBridge {
+//	    function callGolang(address program, string calldata method, bytes calldata args) external returns (bytes memory);
+//	}
+//
+// args and the returned bytes are JSON as in GolangInput
+var GolangBridgeAddress = common.HexToAddress("0x0000000000000000000000000000000000001000")
+
+const callGolangFunc = "callGolang"
+const bridgeGas = 10000
+
+const bridgeABIJSON = `[
+	{"inputs":[{"internalType":"address","name":"program","type":"address"},{"internalType":"string","name":"method","type":"string"},{"internalType":"bytes","name":"args","type":"bytes"}],"name":"callGolang","outputs":[{"internalType":"bytes","name":"","type":"bytes"}],"stateMutability":"nonpayable","type":"function"}
+]`
+
+var bridgeABI = mustParseABI(bridgeABIJSON)
+
+// GolangCaller calls a function of a golang program on behalf of a solidity program
+type GolangCaller func(from common.Address, program common.Address, method string, args []byte) ([]byte, error)
+
+// golangBridge is the precompile of one engine, the precompiles are set per EVM
+type golangBridge struct {
+	e      *Engine
+	result []byte
+	err    error
+}
+
+// RequiredGas runs the call: the gas used by the solidity programs the golang programs call back is only known once
+// they ran, it is charged to the calling frame with the gas of the bridge. Run follows and returns the result.
+func (b *golangBridge) RequiredGas(input []byte) uint64 {
+	e := b.e
+	b.result, b.err = nil, nil
+	// the gas of the bridge frame, recorded by onEnter. the frame fails with out of gas without calling
+	if e.bridgeFrameGas < bridgeGas {
+		return bridgeGas
+	}
+	budget := e.bridgeFrameGas - bridgeGas
+	e.budgets = append(e.budgets, budget)
+	b.result, b.err = e.callGolang(input)
+	left := e.budgets[len(e.budgets)-1]
+	e.budgets = e.budgets[:len(e.budgets)-1]
+	return bridgeGas + budget - left
+}
+
+func (b *golangBridge) Run(input []byte) ([]byte, error) {
+	return b.result, b.err
+}
+
+// SetGolangCaller enables the calls of solidity programs to golang programs
+func (e *Engine) SetGolangCaller(call GolangCaller) {
+	e.golang = call
+}
+
+func (e *Engine) callGolang(input []byte) ([]byte, error) {
+	if e.golang == nil {
+		return nil, fmt.Errorf("golang programs can not be called here")
+	}
+	// a golang program may change its states, which is not allowed in a static call
+	if e.bridgeCallType != vm.CALL {
+		return nil, fmt.Errorf("golang programs can only be called with CALL, not %v", e.bridgeCallType)
+	}
+	method := bridgeABI.Methods[callGolangFunc]
+	if len(input) < 4 || string(input[:4]) != string(method.ID) {
+		return nil, fmt.Errorf("unknown function of the golang bridge")
+	}
+	args, err := method.Inputs.Unpack(input[4:])
+	if err != nil {
+		return nil, fmt.Errorf("failed to unpack: %v", err)
+	}
+	program, ok := args[0].(common.Address)
+	if !ok {
+		return nil, fmt.Errorf("unexpected type: %T", args[0])
+	}
+	name, ok := args[1].(string)
+	if !ok {
+		return nil, fmt.Errorf("unexpected type: %T", args[1])
+	}
+	data, ok := args[2].([]byte)
+	if !ok {
+		return nil, fmt.Errorf("unexpected type: %T", args[2])
+	}
+
+	// the changes of golang programs are not undone when a call running reverts
+	for i := range e.frames {
+		e.frames[i] = true
+	}
+	result, err := e.golang(e.bridgeCaller, program, name, data)
+	if err != nil {
+		return nil, err
+	}
+	return method.Outputs.Pack(result)
+}
+
+// record the programs called and the caller of the bridge, precompiles do not receive it
+func (e *Engine) onEnter(depth int, typ byte, from common.Address, to common.Address, input []byte, gas uint64, value *big.Int) {
+	e.touched[to] = true
+	e.frames = append(e.frames, false)
+	if to == GolangBridgeAddress {
+		e.bridgeCaller = from
+		e.bridgeCallType = vm.OpCode(typ)
+		e.bridgeFrameGas = gas
+	}
+}
+
+// a reverted call that reached the bridge fails the execution, the golang programs it called keep their changes
+func (e *Engine) onExit(depth int, output []byte, gasUsed uint64, err error, reverted bool) {
+	bridged := e.frames[len(e.frames)-1]
+	e.frames = e.frames[:len(e.frames)-1]
+	if reverted && bridged && e.bridgeErr == nil {
+		e.bridgeErr = fmt.Errorf("a call reverted after calling golang programs, their changes can not be undone: %v", err)
+	}
+}
@@ -1,231 +1,222 @@
 package evm
 
 import (
+	"errors"
 	"fmt"
 	"math/big"
-	"tee/help"
-	"tee/pull"
+	"strings"
 
+	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/rawdb"
 	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/core/tracing"
 	"github.com/ethereum/go-ethereum/core/vm"
 	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/triedb"
 	"github.com/holiman/uint256"
 )
 
-const getStatesFunc = "getStates"
-const setStatesFunc = "setStates"
-const getInteractContractsFunc = "getInteractContracts"
-const gas = 90000000000 // set a large gas limit
+// DefaultGasLimit is the gas of the calls of an engine without gas limit
+const DefaultGasLimit = 90000000000
 
-var contractAddress common.Address
-var callerAddress common.Address
-var vmConfig = vm.Config{}
 var chainConfig = params.MainnetChainConfig
-var evmContext = vm.BlockContext{
-	CanTransfer: func(db vm.StateDB, from common.Address, amount *uint256.Int) bool {
-		return db.GetBalance(from).Cmp(amount) >= 0
-	},
-	Transfer: func(db vm.StateDB, from common.Address, to common.Address, amount *uint256.Int) {
-		db.SubBalance(from, amount, tracing.BalanceChangeUnspecified)
-		db.AddBalance(to, amount, tracing.BalanceChangeUnspecified)
-	},
-	GetHash: nil,
-
-	Coinbase:    common.Address{},
-	GasLimit:    uint64(0),
-	BlockNumber: big.NewInt(0),
-	Time:        uint64(0),
-	Difficulty:  big.NewInt(0),
-	BaseFee:     big.NewInt(0), // No base fee
-	BlobBaseFee: big.NewInt(0), // No blob base fee
-	// Random:      &common.Hash{},
 
+// Loader returns the code and states of a deployed program, or ErrNoProgram
+type Loader func(programAddress common.Address) ([]byte, []byte, error)
+
+// Engine is an inner EVM executing the solidity programs
+type Engine struct {
+	load            Loader
+	contractAddress common.Address
+	callerAddress   common.Address
+	evmContext      vm.BlockContext
+	txContext       vm.TxContext
+	statedb         *state.StateDB
+	evm             *vm.EVM
+
+	// programs loaded in the EVM when a call reaches them, see lazyState
+	contracts   []common.Address            // in loading order
+	programs    map[common.Address]*program // nil for the addresses without program
+	touched     map[common.Address]bool     // addresses called
+	precompiles map[common.Address]bool
+	loadErr     error                                   // first program that failed to load, it fails the call
+	slots       map[common.Address]map[common.Hash]bool // storage slots written, see getStates
+
+	gasLimit uint64 // gas of all the calls of the engine
+	gasUsed  uint64
+
+	golang         GolangCaller
+	bridgeCaller   common.Address
+	bridgeCallType vm.OpCode
+	bridgeFrameGas uint64
+	budgets        []uint64 // gas left to the bridge calls running, see golangBridge.RequiredGas
+	frames         []bool   // calls running, true once they reached the bridge
+	bridgeErr      error    // first reverted call that reached the bridge, it fails the call
 }
 
-// initialize EVM environment
-var statedb *state.StateDB
-var evm *vm.EVM
-
-var txContext = vm.TxContext{
-	Origin:   callerAddress,
-	GasPrice: big.NewInt(0),
+func New(load Loader) *Engine {
+	return &Engine{
+		load:     load,
+		gasLimit: DefaultGasLimit,
+		evmContext: vm.BlockContext{
+			CanTransfer: func(db vm.StateDB, from common.Address, amount *uint256.Int) bool {
+				return db.GetBalance(from).Cmp(amount) >= 0
+			},
+			Transfer: func(db vm.StateDB, from common.Address, to common.Address, amount *uint256.Int) {
+				db.SubBalance(from, amount, tracing.BalanceChangeUnspecified)
+				db.AddBalance(to, amount, tracing.BalanceChangeUnspecified)
+			},
+			GetHash: nil,
+
+			Coinbase:    common.Address{},
+			GasLimit:    uint64(0),
+			BlockNumber: big.NewInt(0),
+			Time:        uint64(0),
+			Difficulty:  big.NewInt(0),
+			BaseFee:     big.NewInt(0), // No base fee
+			BlobBaseFee: big.NewInt(0), // No blob base fee
+			// Random:      &common.Hash{},
+		},
+		txContext: vm.TxContext{
+			GasPrice: big.NewInt(0),
+		},
+	}
 }
 
-func SetConfig(_blockNumber *big.Int, _blockTime uint64, _contractAddress common.Address, _callerAddress common.Address) {
-	contractAddress = _contractAddress
-	callerAddress = _callerAddress
-	evmContext.BlockNumber = _blockNumber
-	evmContext.Time = _blockTime
-	txContext.Origin = _callerAddress
+// SetGasLimit sets the gas of all the calls of the engine, 0 keeps DefaultGasLimit
+func (e *Engine) SetGasLimit(gas uint64) {
+	if gas > 0 {
+		e.gasLimit = gas
+	}
 }
 
-func refresh() {
-	var err error
-	statedb, err = state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
-	if err != nil {
-		panic(err)
-	}
-	evm = vm.NewEVM(evmContext, txContext, statedb, chainConfig, vmConfig)
+// GasUsed returns the gas used by the calls of the engine
+func (e *Engine) GasUsed() uint64 {
+	return e.gasUsed
 }
 
-func Deploy(userCode []byte) ([]byte, []byte, error) {
-	refresh()
-	// deploy code
-	code, address, _, err := evm.Create(vm.AccountRef(callerAddress), userCode, uint64(gas), uint256.MustFromBig(big.NewInt(0)))
-	if err != nil {
-		fmt.Println("Error create contract:", err)
-		return nil, nil, err
-	}
+// the rules of mainnet at lower block numbers lack opcodes compilers emit, e.g. REVERT
+var minBlockNumber = big.NewInt(12965000)
 
-	newStates, err := getStates(address)
-	if err != nil {
-		fmt.Println("Error getting states:", err)
-		return nil, nil, err
+func (e *Engine) SetConfig(_blockNumber *big.Int, _blockTime uint64, _contractAddress common.Address, _callerAddress common.Address) {
+	e.contractAddress = _contractAddress
+	e.callerAddress = _callerAddress
+	// only the EVM sees the raised block number, the other VMs get the block of the event
+	if _blockNumber.Cmp(minBlockNumber) < 0 {
+		_blockNumber = minBlockNumber
 	}
-	return newStates, code, nil
+	e.evmContext.BlockNumber = _blockNumber
+	e.evmContext.Time = _blockTime
+	e.txContext.Origin = _callerAddress
 }
 
-func Execute(userCode []byte, states []byte, input []byte) ([]common.Address, [][]byte, [][]byte, interface{}, error) {
-	refresh()
-	// load interact contracts
-	contracts, codes, err := loadInteractContracts(contractAddress)
-	if err != nil {
-		fmt.Println("Error loading interact contracts:", err)
-		return nil, nil, nil, nil, err
-	}
-
-	// execute contract in inner EVM
-	result, _, err := evm.Call(vm.AccountRef(callerAddress), contractAddress, input, uint64(gas), uint256.MustFromBig(big.NewInt(0)))
+// initialize EVM environment
+func (e *Engine) refresh() {
+	var err error
+	e.statedb, err = state.New(common.Hash{}, state.NewDatabase(triedb.NewDatabase(rawdb.NewMemoryDatabase(), nil), nil))
 	if err != nil {
-		fmt.Println("Error executing contract:", err)
-		return nil, nil, nil, nil, err
+		panic(err)
 	}
-
-	// get all states
-	newAllStates, err := getAllStates(contracts)
-	if err != nil {
-		fmt.Println("Error getting states:", err)
-		return nil, nil, nil, nil, err
+	e.contracts = nil
+	e.gasUsed = 0
+	e.programs = map[common.Address]*program{}
+	e.touched = map[common.Address]bool{}
+	e.loadErr = nil
+	e.budgets = nil
+	e.frames = nil
+	e.bridgeErr = nil
+	e.slots = map[common.Address]map[common.Hash]bool{}
+	hooks := &tracing.Hooks{OnEnter: e.onEnter, OnExit: e.onExit, OnStorageChange: e.onStorageChange}
+	e.statedb.SetLogger(hooks)
+	vmConfig := vm.Config{Tracer: hooks}
+	e.evm = vm.NewEVM(e.evmContext, e.txContext, lazyState{e.statedb, e}, chainConfig, vmConfig)
+
+	// the golang bridge is a precompile of this EVM only
+	rules := chainConfig.Rules(e.evmContext.BlockNumber, e.evmContext.Random != nil, e.evmContext.Time)
+	precompiles := vm.ActivePrecompiledContracts(rules)
+	precompiles[GolangBridgeAddress] = &golangBridge{e: e}
+	e.evm.SetPrecompiles(precompiles)
+	e.precompiles = map[common.Address]bool{}
+	for addr := range precompiles {
+		e.precompiles[addr] = true
 	}
-
-	return contracts, newAllStates, codes, result, nil
 }
 
-func loadInteractContracts(contractAddress common.Address) ([]common.Address, [][]byte, error) {
-	// getcontract Details
-	code, states, err := pull.GetProgramDetails(contractAddress, "", "")
+func mustParseABI(abiJSON string) abi.ABI {
+	parsedABI, err := abi.JSON(strings.NewReader(abiJSON))
 	if err != nil {
-		fmt.Println("Error getting contract details:", err)
-		return nil, nil, err
-	}
-	// deploy contract
-	statedb.SetCode(contractAddress, code)
-	err = setStates(contractAddress, states)
-	if err != nil {
-		fmt.Println("Error setting interactContract states:", err)
-		return nil, nil, err
+		panic(err)
 	}
-	// store all interactive contracts address and code
-	contracts := []common.Address{contractAddress}
-	codes := [][]byte{code}
+	return parsedABI
+}
 
-	// get interact contracts
-	getInteractContractsInput, err := help.ParsedSystemABI.Pack(getInteractContractsFunc)
-	if err != nil {
-		fmt.Printf("Failed to pack getInteractContracts function call: %v", err)
+func (e *Engine) Deploy(userCode []byte) ([]byte, []byte, error) {
+	e.refresh()
+	// deploy code
+	code, address, left, err := e.evm.Create(vm.AccountRef(e.callerAddress), userCode, e.gasLimit, uint256.MustFromBig(big.NewInt(0)))
+	e.gasUsed = e.gasLimit - left
+	if err == nil {
+		err = e.bridgeErr
 	}
-	result, _, err := evm.Call(vm.AccountRef(callerAddress), contractAddress, getInteractContractsInput, uint64(gas), uint256.MustFromBig(big.NewInt(0)))
 	if err != nil {
-		fmt.Println("Error executing getInteractContracts function call:", err)
+		fmt.Println("Error create contract:", err)
 		return nil, nil, err
 	}
 
-	// decode result
-	res, err := help.ParsedSystemABI.Unpack(getInteractContractsFunc, result)
-	if err != nil {
-		return nil, nil, fmt.Errorf("failed to unpack: %v", err)
-	}
-
-	if len(res) <= 0 {
-		return nil, nil, fmt.Errorf("empty unpacked result")
-	}
+	return e.getStates(address), code, nil
+}
 
-	// get first return value and ensure its type is []common.Address
-	addrs, ok := res[0].([]common.Address) // 确保类型匹配
-	if !ok {
-		return nil, nil, fmt.Errorf("unexpected type: %T", res[0])
+// Call runs a solidity program on behalf of from, the programs are loaded when the call reaches them
+func (e *Engine) Call(from common.Address, to common.Address, input []byte) ([]byte, error) {
+	if e.evm == nil {
+		e.refresh()
 	}
-	for _, addr := range addrs {
-		subAddrs, subCodes, err := loadInteractContracts(addr)
-		if err != nil {
-			fmt.Println("Error loading interact contracts:", err)
-			return nil, nil, err
-		}
-		contracts = append(contracts, subAddrs...)
-		codes = append(codes, subCodes...)
+	// the calls share the gas of the engine. a call made by a golang program called through the bridge
+	// gets the gas left to the bridge, which charges it to the solidity program calling the bridge.
+	gas := e.gasLimit - min(e.gasUsed, e.gasLimit)
+	nested := len(e.budgets) > 0
+	if nested {
+		gas = e.budgets[len(e.budgets)-1]
 	}
-
-	return contracts, codes, nil
-}
-
-func getAllStates(contractAddr []common.Address) ([][]byte, error) {
-	var allStates [][]byte
-	for _, addr := range contractAddr {
-		states, err := getStates(addr)
-		if err != nil {
-			fmt.Println("Error getting states:", err)
-			return nil, err
-		}
-		allStates = append(allStates, states)
+	if gas == 0 {
+		return nil, vm.ErrOutOfGas
 	}
-	return allStates, nil
-}
-
-func getStates(contractAddr common.Address) ([]byte, error) {
-	// get current states
-	getStatesInput, err := help.ParsedSystemABI.Pack(getStatesFunc)
-	if err != nil {
-		fmt.Printf("Failed to pack function call: %v", err)
+	result, left, err := e.evm.Call(vm.AccountRef(from), to, input, gas, uint256.MustFromBig(big.NewInt(0)))
+	if nested {
+		e.budgets[len(e.budgets)-1] -= gas - left
+	} else {
+		e.gasUsed += gas - left
 	}
-	// execute contract in inner EVM
-	result, _, err := evm.Call(vm.AccountRef(callerAddress), contractAddr, getStatesInput, uint64(gas), uint256.MustFromBig(big.NewInt(0)))
-	if err != nil {
-		fmt.Println("Error executing contract:", err)
-		return nil, err
+	if e.loadErr != nil {
+		fmt.Println("Error loading programs:", e.loadErr)
+		return nil, e.loadErr
 	}
-
-	// unpack result
-	res, err := help.ParsedSystemABI.Unpack(getStatesFunc, result)
-	if err != nil {
-		return nil, fmt.Errorf("failed to unpack: %v", err)
+	if e.bridgeErr != nil {
+		return nil, e.bridgeErr
 	}
-
-	// get first return value and ensure its type is []byte
-	if len(res) > 0 {
-		newStates, ok := res[0].([]byte)
-		if !ok {
-			return nil, fmt.Errorf("unexpected type: %T", res[0])
-		}
-		return newStates, nil
+	if errors.Is(err, vm.ErrExecutionReverted) {
+		return nil, newRevertError(result)
 	}
-	return nil, fmt.Errorf("empty unpacked result")
+	return result, err
 }
 
-func setStates(contractAddr common.Address, states []byte) error {
-	// set new states
-	setStatesInput, err := help.ParsedSystemABI.Pack(setStatesFunc, states)
-	if err != nil {
-		fmt.Printf("Failed to pack function call: %v", err)
-		return err
-	}
-	_, _, err = evm.Call(vm.AccountRef(callerAddress), contractAddr, setStatesInput, uint64(gas), uint256.MustFromBig(big.NewInt(0)))
-	if err != nil {
-		fmt.Println("Error executing contract (setStates):", err)
-		return err
+// Loaded returns the addresses, states and codes of the programs called in the EVM, in loading order
+func (e *Engine) Loaded() ([]common.Address, [][]byte, [][]byte, error) {
+	var contracts []common.Address
+	var states, codes [][]byte
+	for _, addr := range e.contracts {
+		if !e.touched[addr] {
+			continue
+		}
+		// a reverted call also reverted the loading of the programs it reached and all their changes,
+		// their states are unchanged. a program called again after the revert was loaded again.
+		if e.statedb.GetCodeSize(addr) == 0 {
+			continue
+		}
+		contracts = append(contracts, addr)
+		states = append(states, e.getStates(addr))
+		codes = append(codes, e.programs[addr].code)
 	}
-
-	return nil
+	return contracts, states, codes, nil
 }
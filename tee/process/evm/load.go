@@ -0,0 +1,94 @@
+package evm
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/vm"
+)
+
+// ErrNoProgram is returned by a Loader when no program is deployed at the address, e.g. an account
+var ErrNoProgram = errors.New("no program at this address")
+
+// program loaded in the EVM
+type program struct {
+	code   []byte
+	states []byte
+}
+
+// lazyState loads the programs into the state when the EVM first looks at their code,
+// so that only the programs a call reaches are loaded
+type lazyState struct {
+	*state.StateDB
+	e *Engine
+}
+
+var _ vm.StateDB = lazyState{}
+
+func (s lazyState) Exist(addr common.Address) bool {
+	s.e.ensure(addr)
+	return s.StateDB.Exist(addr)
+}
+
+func (s lazyState) Empty(addr common.Address) bool {
+	s.e.ensure(addr)
+	return s.StateDB.Empty(addr)
+}
+
+func (s lazyState) GetCode(addr common.Address) []byte {
+	s.e.ensure(addr)
+	return s.StateDB.GetCode(addr)
+}
+
+func (s lazyState) GetCodeSize(addr common.Address) int {
+	s.e.ensure(addr)
+	return s.StateDB.GetCodeSize(addr)
+}
+
+func (s lazyState) GetCodeHash(addr common.Address) common.Hash {
+	s.e.ensure(addr)
+	return s.StateDB.GetCodeHash(addr)
+}
+
+// ensure the program at addr is in the state if there is one.
+// a call reverted after loading a program also reverts the loading, the program is then loaded again.
+func (e *Engine) ensure(addr common.Address) {
+	if e.precompiles[addr] || e.loadErr != nil {
+		return
+	}
+	p, tried := e.programs[addr]
+	if !tried {
+		p = e.loadProgram(addr)
+		e.programs[addr] = p
+		if p != nil {
+			e.contracts = append(e.contracts, addr)
+		}
+	}
+	if p == nil || e.statedb.GetCodeSize(addr) != 0 {
+		return
+	}
+	e.statedb.SetCode(addr, p.code)
+	err := e.setStates(addr, p.states)
+	if err != nil {
+		e.loadErr = fmt.Errorf("failed to set states of program %v: %v", addr.Hex(), err)
+	}
+}
+
+// loadProgram returns the program at addr, nil when there is none
+func (e *Engine) loadProgram(addr common.Address) *program {
+	if e.load == nil {
+		return nil
+	}
+	code, states, err := e.load(addr)
+	if errors.Is(err, ErrNoProgram) {
+		return nil
+	}
+	if err != nil {
+		// the state can not report errors, the call fails once it returns
+		e.loadErr = fmt.Errorf("failed to load program %v: %v", addr.Hex(), err)
+		return nil
+	}
+	return &program{code: code, states: states}
+}
@@ -0,0 +1,78 @@
+package evm
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+var (
+	testCaller  = common.HexToAddress("0x01")
+	testProgram = common.HexToAddress("0xa0")
+	testCallee  = common.HexToAddress("0xb0")
+)
+
+// the program at 0xa0 calls 0xb0 and ignores its failure
+const callCallee = "0x6000600060006000600060b05af15000"
+
+// states of a program whose slot 0 is value
+func slotStates(value byte) []byte {
+	states := []byte{storageFormat}
+	states = append(states, common.Hash{}.Bytes()...)
+	return append(states, common.BytesToHash([]byte{value}).Bytes()...)
+}
+
+func TestLoadedCallee(t *testing.T) {
+	tests := []struct {
+		name   string
+		callee string // sets slot 0 to 2
+		want   map[common.Address][]byte
+	}{
+		{
+			name:   "callee returns",
+			callee: "0x600260005500",
+			want:   map[common.Address][]byte{testProgram: {storageFormat}, testCallee: slotStates(2)},
+		},
+		{
+			// the loading of the callee is reverted with its changes, its states must not be output empty
+			name:   "callee reverts",
+			callee: "0x600260005560006000fd",
+			want:   map[common.Address][]byte{testProgram: {storageFormat}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			programs := map[common.Address]program{
+				testProgram: {code: hexutil.MustDecode(callCallee)},
+				testCallee:  {code: hexutil.MustDecode(tt.callee), states: slotStates(1)},
+			}
+			e := New(func(addr common.Address) ([]byte, []byte, error) {
+				p, ok := programs[addr]
+				if !ok {
+					return nil, nil, ErrNoProgram
+				}
+				return p.code, p.states, nil
+			})
+			e.SetConfig(big.NewInt(1), 1, testProgram, testCaller)
+			_, err := e.Call(testCaller, testProgram, nil)
+			if err != nil {
+				t.Fatalf("call failed: %v", err)
+			}
+			addresses, states, _, err := e.Loaded()
+			if err != nil {
+				t.Fatalf("failed to get the loaded programs: %v", err)
+			}
+			if len(addresses) != len(tt.want) {
+				t.Fatalf("loaded %v, want %d programs", addresses, len(tt.want))
+			}
+			for i, addr := range addresses {
+				if !bytes.Equal(states[i], tt.want[addr]) {
+					t.Errorf("states of %v are %x, want %x", addr.Hex(), states[i], tt.want[addr])
+				}
+			}
+		})
+	}
+}
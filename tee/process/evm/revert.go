@@ -0,0 +1,33 @@
+package evm
+
+import (
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// RevertError is returned by a call reverted by a solidity program, with the data of the revert
+type RevertError struct {
+	Data   []byte // ABI encoded Error(string), Panic(uint256) or custom error, empty for a bare revert
+	Reason string // decoded Error(string) or Panic(uint256), empty for a custom error
+}
+
+func newRevertError(data []byte) *RevertError {
+	// custom errors can not be decoded without the ABI of the program
+	reason, err := abi.UnpackRevert(data)
+	if err != nil {
+		reason = ""
+	}
+	return &RevertError{Data: data, Reason: reason}
+}
+
+func (e *RevertError) Error() string {
+	switch {
+	case e.Reason != "":
+		return fmt.Sprintf("execution reverted: %s", e.Reason)
+	case len(e.Data) > 0:
+		return fmt.Sprintf("execution reverted: custom error %s", hexutil.Encode(e.Data))
+	}
+	return "execution reverted"
+}
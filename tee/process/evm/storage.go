@@ -0,0 +1,77 @@
+package evm
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// The states of a solidity program are the raw slots of its storage, so that any contract can be deployed
+// without serializing its variables. They are encoded as the non-zero slots sorted by key, each as the
+// 32 bytes of the key followed by the 32 bytes of the value, the same storage always gives the same bytes.
+// The slots follow a format byte: the states returned by the getStates function of the contracts before were
+// ABI encoded, they start with a zero byte and are rejected instead of being read as slots.
+const slotSize = 2 * common.HashLength
+
+const storageFormat byte = 1
+
+// record a slot written by a program, the storage of a program is read back from the slots it may have written
+func (e *Engine) onStorageChange(addr common.Address, slot common.Hash, prev common.Hash, new common.Hash) {
+	if e.slots[addr] == nil {
+		e.slots[addr] = map[common.Hash]bool{}
+	}
+	e.slots[addr][slot] = true
+}
+
+// getStates returns the encoded storage of a program
+func (e *Engine) getStates(contractAddr common.Address) []byte {
+	keys := make([]common.Hash, 0, len(e.slots[contractAddr]))
+	for slot := range e.slots[contractAddr] {
+		keys = append(keys, slot)
+	}
+	sort.Slice(keys, func(i, j int) bool { return bytes.Compare(keys[i][:], keys[j][:]) < 0 })
+
+	states := make([]byte, 0, 1+len(keys)*slotSize)
+	states = append(states, storageFormat)
+	for _, slot := range keys {
+		value := e.statedb.GetState(contractAddr, slot)
+		if value == (common.Hash{}) {
+			continue
+		}
+		states = append(states, slot[:]...)
+		states = append(states, value[:]...)
+	}
+	return states
+}
+
+// setStates restores the storage of a program from its encoded states
+func (e *Engine) setStates(contractAddr common.Address, states []byte) error {
+	// a program without storage saved by a contract before had empty states too
+	if len(states) == 0 {
+		return nil
+	}
+	if states[0] != storageFormat {
+		return fmt.Errorf("the states were encoded by the getStates function of the contract, the program must be deployed again")
+	}
+	states = states[1:]
+	if len(states)%slotSize != 0 {
+		return fmt.Errorf("invalid states: %d bytes is not a multiple of %d", len(states), slotSize)
+	}
+	var prev common.Hash
+	for i := 0; i < len(states); i += slotSize {
+		slot := common.BytesToHash(states[i : i+common.HashLength])
+		value := common.BytesToHash(states[i+common.HashLength : i+slotSize])
+		if i > 0 && bytes.Compare(slot[:], prev[:]) <= 0 {
+			return fmt.Errorf("invalid states: slot %v after %v", slot.Hex(), prev.Hex())
+		}
+		if value == (common.Hash{}) {
+			return fmt.Errorf("invalid states: zero value in slot %v", slot.Hex())
+		}
+		// the slot is recorded by onStorageChange
+		e.statedb.SetState(contractAddr, slot, value)
+		prev = slot
+	}
+	return nil
+}
@@ -1,17 +1,17 @@
 package process
 
 import (
-	"bytes"
-	"encoding/gob"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 
 	"tee/help"
 	"tee/key"
-	"tee/ocs"
-	"tee/process/cache"
+	"tee/node"
 	"tee/process/compacity"
+	"tee/process/evm"
+	pb "tee/proto"
 	"tee/pull"
 	"tee/utils"
 
@@ -20,99 +20,154 @@ import (
 	"google.golang.org/protobuf/proto"
 )
 
-func Execute(event map[string]interface{}) []help.Output {
-	data := event["data"].(map[string]interface{})
-	encryptedResultKey := data["encryptedResultKey"].([]byte)
-	programAddress := data["programAddress"].(common.Address)
+func Execute(n *node.Node, event map[string]interface{}) ([]help.Output, error) {
+	data, err := utils.Field[map[string]interface{}](event, "data")
+	if err != nil {
+		return nil, fail("Malformed event", err)
+	}
+	encryptedResultKey, err := utils.Field[[]byte](data, "encryptedResultKey")
+	if err != nil {
+		return nil, fail("Malformed event", err)
+	}
+	programAddress, err := utils.Field[common.Address](data, "programAddress")
+	if err != nil {
+		return nil, fail("Malformed event", err)
+	}
+	txPubKey, err := utils.Field[[]byte](data, "transactionKey")
+	if err != nil {
+		return nil, fail("Malformed event", err)
+	}
+	encryptedinput, err := utils.Field[[]byte](data, "encryptedInput")
+	if err != nil {
+		return nil, fail("Malformed event", err)
+	}
+	caller, err := utils.Field[common.Address](data, "caller")
+	if err != nil {
+		return nil, fail("Malformed event", err)
+	}
 
 	// get program info, prepare for execution
-	info, err := pull.GetProgramInfo(programAddress)
+	info, err := pull.GetProgramInfo(n, programAddress)
 	if err != nil {
-		fmt.Printf("Failed to get program info: %v", err)
-		return []help.Output{help.ErrorOutput("Failed to get program info", programAddress, encryptedResultKey)}
+		return nil, fail("Failed to get program info", err)
 	}
 
 	stateKey := info.Keys[len(info.Keys)-1]
 	codeKey := info.CodeKey
 	// get program details
-	code, states, err := pull.GetProgramDetails(programAddress, stateKey, codeKey)
+	code, states, err := pull.GetProgramDetails(n, programAddress, stateKey, codeKey)
 	if err != nil {
-		fmt.Printf("Failed to get program details: %v", err)
-		return []help.Output{help.ErrorOutput("Failed to get program details", programAddress, encryptedResultKey)}
+		return nil, fail("Failed to get program details", err)
 	}
 
 	// get result key
-	txPubKey := data["transactionKey"].([]byte)
 	txPubKeyStr := hex.EncodeToString(txPubKey)
-	resultKey, err := key.ECIESDecrypt(encryptedResultKey, txPubKeyStr)
+	resultKey, err := n.Keys.ECIESDecrypt(encryptedResultKey, txPubKeyStr)
 	if err != nil {
-		fmt.Printf("Failed to decrypt result key: %v", err)
-		return []help.Output{help.ErrorOutput("Failed to decrypt result key", programAddress, encryptedResultKey)}
+		return nil, fail("Failed to decrypt result key", err)
 	}
 
 	// parse input
-	encryptedinput := data["encryptedInput"].([]byte)
-	input, err := key.ECIESDecrypt(encryptedinput, txPubKeyStr)
+	inputBytes, err := n.Keys.ECIESDecrypt(encryptedinput, txPubKeyStr)
 	if err != nil {
-		fmt.Printf("Failed to decrypt input: %v", err)
-		return []help.Output{help.ErrorOutput("Failed to decrypt input", programAddress, encryptedResultKey)}
+		return nil, fail("Failed to decrypt input", err)
+	}
+	var input pb.ExecutionInput
+	err = proto.Unmarshal(inputBytes, &input)
+	if err != nil {
+		return nil, fail("Failed to decode input", err)
 	}
 
 	// execute the program
-	conf := compacity.GetCompacityConfig(event)
-	addresses, newStates, codes, result, err := compacity.Execute(code, states, input, conf)
+	conf, err := compacity.GetCompacityConfig(event)
+	if err != nil {
+		return nil, fail("Malformed event", err)
+	}
+	conf.VM = info.VM
+	conf.Seed = seed(n, event, programAddress)
+	// the ceilings of the TEE may have been lowered since the deployment
+	conf.Limits = limits(n, info.TimeoutMs, info.MemoryMB)
+	// the caller may lower the gas of the program for this execution
+	conf.GasLimit = gasLimit(n, info.GasLimit)
+	if input.GasLimit > conf.GasLimit {
+		return nil, fail("Gas limit above the program limit", fmt.Errorf("gas %v above %v", input.GasLimit, conf.GasLimit))
+	}
+	if input.GasLimit > 0 {
+		conf.GasLimit = input.GasLimit
+	}
+	// a program calls the programs of its VM and, through the bridge, the programs of the other VM
+	conf.Loader = loader(n, pb.VMType_Solidity)
+	conf.GolangLoader = loader(n, pb.VMType_Golang)
+	conf.Pool = n.Programs
+	addresses, newStates, codes, result, gasUsed, err := compacity.Execute(code, states, input.Input, conf)
 	if err != nil {
-		fmt.Printf("Failed to execute program: %v", err)
-		return []help.Output{help.ErrorOutput("Failed to execute program", programAddress, encryptedResultKey)}
+		return nil, &Failure{Msg: "Failed to execute program", Err: err, GasUsed: gasUsed}
 	}
 
 	// save new states to cache
-	cache.SetBatchProgramDetails(addresses, codes, newStates)
+	n.Cache.SetBatchProgramDetails(addresses, codes, newStates)
 
 	// prepare output
-	caller := data["caller"].(common.Address).String()
-	outputs := prepareOutput(addresses, newStates, result, resultKey, programAddress, encryptedResultKey, caller)
-	return outputs
+	return prepareOutput(n, addresses, newStates, result, gasUsed, resultKey, encryptedResultKey, caller.String())
+}
+
+// loader returns the code and states of the programs run by the given VM
+func loader(n *node.Node, vm pb.VMType) func(common.Address) ([]byte, []byte, error) {
+	return func(addr common.Address) ([]byte, []byte, error) {
+		info, err := pull.GetProgramInfo(n, addr)
+		if errors.Is(err, pull.ErrUnknownProgram) {
+			// an account or a contract deployed outside the TEE
+			return nil, nil, evm.ErrNoProgram
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		if info.VM != vm {
+			return nil, nil, fmt.Errorf("program %v is not a %v program", addr.Hex(), vm)
+		}
+		return pull.GetProgramDetails(n, addr, "", "")
+	}
 }
 
 // Function to prepare output
-func prepareOutput(addresses []common.Address, newStates [][]byte, result interface{}, resultKey []byte, programAddress common.Address, encryptedResultKey []byte, caller string) []help.Output {
-	res, err := toBytes(result)
+func prepareOutput(n *node.Node, addresses []common.Address, newStates [][]byte, result interface{}, gasUsed uint64, resultKey []byte, encryptedResultKey []byte, caller string) ([]help.Output, error) {
+	// the result is JSON for every VM, as the results of the calls between golang programs
+	res, err := json.Marshal(result)
 	if err != nil {
-		fmt.Printf("Failed to convert result: %v", err)
-		return []help.Output{help.ErrorOutput("Failed to convert result", programAddress, encryptedResultKey)}
+		return nil, fail("Failed to convert result", err)
+	}
+	envelope, err := proto.Marshal(&pb.ExecutionResult{Result: res, GasUsed: gasUsed})
+	if err != nil {
+		return nil, fail("Failed to encode result", err)
 	}
 	// encrypt result
-	encryptedResult, err := key.EncryptAES(res, string(resultKey))
+	encryptedResult, err := key.EncryptAES(envelope, string(resultKey))
 	if err != nil {
-		fmt.Printf("Failed to encrypt result: %v", err)
-		return []help.Output{help.ErrorOutput("Failed to encrypt result", programAddress, encryptedResultKey)}
+		return nil, fail("Failed to encrypt result", err)
 	}
 
 	var outputs []help.Output
 	for i, addr := range addresses {
 		state := newStates[i]
 
-		info, err := pull.GetProgramInfo(addr)
+		info, err := pull.GetProgramInfo(n, addr)
 		if err != nil {
-			fmt.Printf("Failed to get program info: %v", err)
-			return []help.Output{help.ErrorOutput("Failed to get program info", programAddress, encryptedResultKey)}
+			return nil, fail("Failed to get program info", err)
 		}
 
 		// check if caller is in ACL
 		ALC := info.ACL
 		if len(ALC) != 0 && !utils.Contains(ALC, caller) {
-			fmt.Printf("Caller %v is not in ACL", caller)
-			return []help.Output{help.ErrorOutput("Caller is not in ACL", programAddress, encryptedResultKey)}
+			return nil, fail("Caller is not in ACL", fmt.Errorf("caller %v is not in the ACL of %v", caller, addr.Hex()))
 		}
 
 		info.Nounce = uint32(rand.Intn(1000000)) // set nounce to a random number
 		info.ExecutionCount += 1                 // increase executionCount
 		// rotate key
 		if info.KeyRotation != 0 && info.ExecutionCount%info.KeyRotation == 0 {
-			k, error := key.GenerateAESKey()
-			if error != nil {
-				panic(fmt.Sprintf("Failed to generate AES key: %v", error))
+			k, err := key.GenerateAESKey()
+			if err != nil {
+				return nil, fail("Failed to generate AES key", err)
 			}
 			if info.HistoryKeyDiscard {
 				info.Keys = []string{string(k)}
@@ -124,28 +179,28 @@ func prepareOutput(addresses []common.Address, newStates [][]byte, result interf
 		}
 		infoBytes, err := proto.Marshal(info) // encode info
 		if err != nil {
-			panic(fmt.Sprintf("Failed to encode info: %v", err))
+			return nil, fail("Failed to encode info", err)
 		}
 
 		// prepare output
-		encryptedInfo, err := key.EncryptAES(infoBytes, key.KeyMgt)
+		encryptedInfo, err := key.EncryptAES(infoBytes, n.Keys.KeyMgt)
 		if err != nil {
-			panic(fmt.Sprintf("Failed to encrypt info: %v", err))
+			return nil, fail("Failed to encrypt info", err)
 		}
 
 		stateKey := info.Keys[len(info.Keys)-1]
 		encryptedStates, err := key.EncryptAES([]byte(state), stateKey)
 		if err != nil {
-			panic(fmt.Sprintf("Failed to encrypt states: %v", err))
+			return nil, fail("Failed to encrypt states", err)
 		}
 
 		// save states off-chain
 		statesHash := key.GetHash(encryptedStates)
-		ocs.SetStates(addr, statesHash, encryptedStates)
+		n.OCS.SetStates(addr, statesHash, encryptedStates)
 
 		// save info off-chain
 		infoHash := key.GetHash(encryptedInfo)
-		ocs.SetInfo(addr, infoHash, encryptedInfo)
+		n.OCS.SetInfo(addr, infoHash, encryptedInfo)
 
 		// prepare output
 		var output help.Output
@@ -172,38 +227,7 @@ func prepareOutput(addresses []common.Address, newStates [][]byte, result interf
 		outputs = append(outputs, output)
 
 		// save info to cache
-		cache.SetProgramInfo(addr, info)
-	}
-	return outputs
-}
-
-// general interface{} to []byte
-func toBytes(input interface{}) ([]byte, error) {
-	if input == nil {
-		return nil, nil
-	}
-
-	if b, ok := input.([]byte); ok {
-		return b, nil
-	}
-
-	if str, ok := input.(string); ok {
-		return []byte(str), nil
-	}
-
-	switch v := input.(type) {
-	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64, bool:
-		return []byte(fmt.Sprintf("%v", v)), nil
-	}
-
-	if jsonBytes, err := json.Marshal(input); err == nil {
-		return jsonBytes, nil
-	}
-
-	var buf bytes.Buffer
-	enc := gob.NewEncoder(&buf)
-	if err := enc.Encode(input); err != nil {
-		return nil, fmt.Errorf("failed to encode input: %v", err)
+		n.Cache.SetProgramInfo(addr, info)
 	}
-	return buf.Bytes(), nil
+	return outputs, nil
 }
@@ -1,55 +1,234 @@
 package golang
 
 import (
+	"encoding/json"
 	"fmt"
 	"tee/process/golang/vm"
+
+	"github.com/ethereum/go-ethereum/common"
 )
 
-func Deploy(userCode []byte) ([]byte, error) {
+// Loader returns the code and states of a deployed golang program
+type Loader func(programAddress common.Address) ([]byte, []byte, error)
+
+// Deploy initializes the program and returns its initial states, the programs it declares are loaded with load.
+// the interpreters are taken from pool, which may be nil.
+func Deploy(userCode []byte, ctx vm.Context, load Loader, pool *vm.Pool) ([]byte, error) {
 	// dynamic load user code
-	err := vm.InitializeInterpreter(userCode)
+	v, err := pool.Get(userCode, ctx)
 	if err != nil {
 		fmt.Println("Error initializing interpreter:", err)
 		return nil, err
 	}
+	defer pool.Put(v)
 
 	// save the initial state
-	state, err := vm.GetStates()
+	state, err := v.GetStates()
 	if err != nil {
 		fmt.Println("Error saving state:", err)
 		return nil, err
 	}
-	return state, nil
-}
 
-func Execute(userCode []byte, state []byte, funcName string, args string) ([]byte, interface{}, error) {
-	// dynamic load user code
-	err := vm.InitializeInterpreter(userCode)
+	err = checkInteract(v, ctx, load, pool)
 	if err != nil {
-		fmt.Println("Error initializing interpreter:", err)
-		return nil, nil, err
+		fmt.Println("Error checking interact contracts:", err)
+		return nil, err
 	}
+	return state, nil
+}
 
-	// load the state
-	err = vm.SetStates(state)
+// SolidityCaller calls a solidity program on behalf of a golang program, the input and result are ABI encoded
+type SolidityCaller func(from common.Address, program common.Address, input []byte) ([]byte, error)
+
+// NewSession prepares an execution, the programs called are loaded with load, solidity programs are called with solidity.
+// the interpreters are taken from pool, which may be nil.
+func NewSession(ctx vm.Context, load Loader, solidity SolidityCaller, pool *vm.Pool) *Session {
+	return &Session{load: load, solidity: solidity, ctx: ctx, pool: pool, programs: map[common.Address]*program{}}
+}
+
+// Execute calls a function of the program of the context on behalf of the caller of the context
+func (s *Session) Execute(userCode []byte, state []byte, funcName string, args []byte) (interface{}, error) {
+	err := s.add(s.ctx.ProgramAddress, userCode, state)
 	if err != nil {
-		fmt.Println("Error loading state:", err)
-		return nil, nil, err
+		fmt.Println("Error loading programs:", err)
+		return nil, err
 	}
 
 	// Call
-	result, err := vm.CallMethod(funcName, args)
+	result, err := s.invoke(s.ctx.Caller, s.ctx.ProgramAddress, funcName, args)
+	if err == nil {
+		err = s.err
+	}
 	if err != nil {
 		fmt.Println("Error calling method:", err)
-		return nil, nil, err
+		return nil, err
 	}
+	return result, nil
+}
+
+// Call a function of a golang program on behalf of a solidity program, the program is loaded on first use
+func (s *Session) Call(from common.Address, to common.Address, method string, args []byte) ([]byte, error) {
+	res, err := s.callFromSolidity(from, to, method, args)
+	s.record(from, to, err)
+	return res, err
+}
 
-	// Save the updated state
-	state, err = vm.GetStates()
+func (s *Session) callFromSolidity(from common.Address, to common.Address, method string, args []byte) ([]byte, error) {
+	err := s.loadProgram(to)
 	if err != nil {
-		fmt.Println("Error saving state:", err)
-		return nil, nil, err
+		return nil, err
+	}
+	result, err := s.invoke(from, to, method, args)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(result)
+}
+
+// Err returns the first failed call between programs, it fails the execution even if the calling program ignored it
+func (s *Session) Err() error {
+	return s.err
+}
+
+// Loaded returns the addresses, states and codes of the programs called, in calling order.
+// a declared program is loaded by its first call, so a program never called has no output.
+func (s *Session) Loaded() ([]common.Address, [][]byte, [][]byte, error) {
+	states := make([][]byte, len(s.order))
+	codes := make([][]byte, len(s.order))
+	for i, addr := range s.order {
+		p := s.programs[addr]
+		state, err := p.vm.GetStates()
+		if err != nil {
+			fmt.Println("Error saving state:", err)
+			return nil, nil, nil, err
+		}
+		states[i] = state
+		codes[i] = p.code
+	}
+	return s.order, states, codes, nil
+}
+
+// Close returns the interpreters to the pool, also after a failure, the session is no longer used
+func (s *Session) Close() {
+	for _, v := range s.vms {
+		s.pool.Put(v)
+	}
+	s.vms = nil
+}
+
+// Session holds the golang programs loaded by one execution, they call each other through chain.Call
+type Session struct {
+	load     Loader
+	solidity SolidityCaller
+	ctx      vm.Context
+	pool     *vm.Pool
+	programs map[common.Address]*program
+	order    []common.Address
+	vms      []*vm.VM // interpreters taken from the pool, put back by Close
+	err      error    // first failed call between programs
+}
+
+type program struct {
+	vm       *vm.VM
+	code     []byte
+	interact map[common.Address]bool
+	running  bool
+}
+
+// add a program, the programs it declares are added by their first call
+func (s *Session) add(addr common.Address, code []byte, state []byte) error {
+	ctx := s.ctx
+	ctx.ProgramAddress = addr
+	ctx.Call = func(to common.Address, method string, args []byte) ([]byte, error) {
+		return s.call(addr, to, method, args)
+	}
+	ctx.CallSolidity = func(to common.Address, input []byte) ([]byte, error) {
+		return s.callSolidity(addr, to, input)
+	}
+	v, err := s.pool.Get(code, ctx)
+	if err != nil {
+		return fmt.Errorf("failed to initialize program %v: %w", addr.Hex(), err)
+	}
+	s.vms = append(s.vms, v)
+	err = v.SetStates(state)
+	if err != nil {
+		return fmt.Errorf("failed to load states of program %v: %w", addr.Hex(), err)
+	}
+	interact, err := v.InteractContracts()
+	if err != nil {
+		return fmt.Errorf("failed to get interact contracts of program %v: %w", addr.Hex(), err)
+	}
+
+	p := &program{vm: v, code: code, interact: map[common.Address]bool{}}
+	for _, to := range interact {
+		p.interact[to] = true
+	}
+	s.programs[addr] = p
+	s.order = append(s.order, addr)
+	return nil
+}
+
+// loadProgram adds a program on its first call
+func (s *Session) loadProgram(addr common.Address) error {
+	if _, loaded := s.programs[addr]; loaded {
+		return nil
+	}
+	code, state, err := s.load(addr)
+	if err != nil {
+		return fmt.Errorf("failed to load program %v: %v", addr.Hex(), err)
+	}
+	return s.add(addr, code, state)
+}
+
+// call a function of the program to on behalf of the program from, the result is JSON encoded
+func (s *Session) call(from common.Address, to common.Address, method string, args []byte) ([]byte, error) {
+	res, err := s.callProgram(from, to, method, args)
+	s.record(from, to, err)
+	return res, err
+}
+
+func (s *Session) callProgram(from common.Address, to common.Address, method string, args []byte) ([]byte, error) {
+	if !s.programs[from].interact[to] {
+		return nil, fmt.Errorf("program %v is not returned by GetInteractContracts", to.Hex())
+	}
+	err := s.loadProgram(to)
+	if err != nil {
+		return nil, err
+	}
+	result, err := s.invoke(from, to, method, args)
+	if err != nil {
+		return nil, err
 	}
+	return json.Marshal(result)
+}
 
-	return state, result, nil
+// call a solidity program on behalf of the program from
+func (s *Session) callSolidity(from common.Address, to common.Address, input []byte) ([]byte, error) {
+	if s.solidity == nil {
+		return nil, fmt.Errorf("solidity programs can not be called here")
+	}
+	res, err := s.solidity(from, to, input)
+	s.record(from, to, err)
+	return res, err
+}
+
+// invoke a function of a loaded program
+func (s *Session) invoke(from common.Address, to common.Address, method string, args []byte) (interface{}, error) {
+	p := s.programs[to]
+	// the interpreter of a program can not be entered twice
+	if p.running {
+		return nil, fmt.Errorf("reentrant call to program %v", to.Hex())
+	}
+	p.running = true
+	defer func() { p.running = false }()
+
+	p.vm.SetCaller(from)
+	return p.vm.CallMethod(method, args)
+}
+
+// record the first failed call
+func (s *Session) record(from common.Address, to common.Address, err error) {
+	if err != nil && s.err == nil {
+		s.err = fmt.Errorf("call from %v to %v failed: %w", from.Hex(), to.Hex(), err)
+	}
 }
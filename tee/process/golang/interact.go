@@ -0,0 +1,94 @@
+package golang
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"tee/process/golang/vm"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// maxInteractDepth bounds the chains of programs declared by GetInteractContracts
+const maxInteractDepth = 8
+
+// checkInteract resolves the programs the deployed program declares, recursively, so that a cycle or a chain
+// deeper than maxInteractDepth is rejected at deploy instead of at every execution.
+// the declared programs must already be deployed.
+// solidity programs declare nothing since the EVM loads the programs a call reaches, each once, and a program
+// reached by both VMs is rejected when their outputs are merged, see compacity.
+func checkInteract(v *vm.VM, ctx vm.Context, load Loader, pool *vm.Pool) error {
+	interact, err := v.InteractContracts()
+	if err != nil {
+		return err
+	}
+	if len(interact) > 0 && load == nil {
+		return fmt.Errorf("programs can not be declared here")
+	}
+	r := &resolver{ctx: ctx, load: load, pool: pool, done: map[common.Address]bool{}}
+	return r.visit(interact, []common.Address{ctx.ProgramAddress})
+}
+
+type resolver struct {
+	ctx  vm.Context
+	load Loader
+	pool *vm.Pool
+	done map[common.Address]bool // programs whose declared programs are resolved, shared dependencies are visited once
+}
+
+// visit the programs declared by the last program of path
+func (r *resolver) visit(interact []common.Address, path []common.Address) error {
+	for _, to := range interact {
+		if i := slices.Index(path, to); i >= 0 {
+			return &vm.CodeError{Err: fmt.Errorf("programs declared by GetInteractContracts form a cycle: %s", formatPath(append(path[i:], to)))}
+		}
+		if r.done[to] {
+			continue
+		}
+		if len(path) > maxInteractDepth {
+			return &vm.CodeError{Err: fmt.Errorf("programs declared by GetInteractContracts are nested deeper than %d: %s", maxInteractDepth, formatPath(append(path, to)))}
+		}
+		next, err := r.declared(to)
+		if err != nil {
+			return err
+		}
+		err = r.visit(next, append(path[:len(path):len(path)], to))
+		if err != nil {
+			return err
+		}
+		r.done[to] = true
+	}
+	return nil
+}
+
+// declared returns the programs declared by a deployed program
+func (r *resolver) declared(addr common.Address) ([]common.Address, error) {
+	code, state, err := r.load(addr)
+	if err != nil {
+		return nil, &vm.CodeError{Err: fmt.Errorf("failed to load declared program %v: %v", addr.Hex(), err)}
+	}
+	ctx := r.ctx
+	ctx.ProgramAddress = addr
+	v, err := r.pool.Get(code, ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize program %v: %w", addr.Hex(), err)
+	}
+	defer r.pool.Put(v)
+	err = v.SetStates(state)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load states of program %v: %w", addr.Hex(), err)
+	}
+	interact, err := v.InteractContracts()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get interact contracts of program %v: %w", addr.Hex(), err)
+	}
+	return interact, nil
+}
+
+func formatPath(path []common.Address) string {
+	hexes := make([]string, len(path))
+	for i, addr := range path {
+		hexes[i] = addr.Hex()
+	}
+	return strings.Join(hexes, " -> ")
+}
@@ -0,0 +1,56 @@
+package vm
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// ChainPackage is the host package exposing the context of the transaction to user programs:
+//
+//	import "tee/chain"
+//
+//	chain.Caller() string          hex address of the caller, the calling program in a cross-program call
+//	chain.ProgramAddress() string  hex address of the program
+//	chain.BlockNumber() uint64
+//	chain.BlockTime() uint64
+//	chain.Seed() int64             deterministic random seed, the same in every TEE
+//	chain.Call(program, method string, args []byte) ([]byte, error)
+//	                               calls an exported function of a program returned by GetInteractContracts,
+//	                               args and result are JSON as in GolangInput
+//	chain.CallSolidity(program string, input []byte) ([]byte, error)
+//	                               calls a solidity program, input and result are ABI encoded
+const ChainPackage = "tee/chain"
+
+// host packages user programs may import besides the standard library
+var hostPackages = []string{ChainPackage, FilesPackage, StorePackage}
+
+// symbols of the chain package, see symbols
+func chainSymbols(ctx *Context, use func()) map[string]reflect.Value {
+	return map[string]reflect.Value{
+		"Caller":         hook(func() string { return ctx.Caller.Hex() }, use),
+		"ProgramAddress": hook(func() string { return ctx.ProgramAddress.Hex() }, use),
+		"BlockNumber":    hook(func() uint64 { return ctx.BlockNumber }, use),
+		"BlockTime":      hook(func() uint64 { return ctx.BlockTime }, use),
+		"Seed":           hook(func() int64 { return ctx.Seed }, use),
+		"Call": hook(func(program string, method string, args []byte) ([]byte, error) {
+			if ctx.Call == nil {
+				return nil, fmt.Errorf("programs can not be called during deploy")
+			}
+			if !common.IsHexAddress(program) {
+				return nil, fmt.Errorf("invalid program address: %q", program)
+			}
+			return ctx.Call(common.HexToAddress(program), method, args)
+		}, use),
+		"CallSolidity": hook(func(program string, input []byte) ([]byte, error) {
+			if ctx.CallSolidity == nil {
+				return nil, fmt.Errorf("programs can not be called during deploy")
+			}
+			if !common.IsHexAddress(program) {
+				return nil, fmt.Errorf("invalid program address: %q", program)
+			}
+			return ctx.CallSolidity(common.HexToAddress(program), input)
+		}, use),
+	}
+}
@@ -0,0 +1,102 @@
+package vm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"runtime/metrics"
+	"time"
+
+	"github.com/traefik/yaegi/interp"
+)
+
+// Limits is the budget of one deploy or execution, a zero value disables the limit.
+// the time and memory used depend on the load of the host, so exceeding them is not a result of the program, see IsLimit.
+type Limits struct {
+	Timeout time.Duration
+	Memory  uint64 // bytes of heap the program may grow
+}
+
+var (
+	ErrTimeout     = errors.New("execution time limit exceeded")
+	ErrMemoryLimit = errors.New("memory limit exceeded")
+)
+
+// IsLimit reports whether err is caused by exceeding the limits, which another TEE may not exceed
+func IsLimit(err error) bool {
+	return errors.Is(err, ErrTimeout) || errors.Is(err, ErrMemoryLimit)
+}
+
+const memoryCheckInterval = 5 * time.Millisecond
+
+// host package used to run the calls of the TEE inside the interpreter, so that they can be cancelled.
+// user code can not import it since it is not in the allowlist.
+const (
+	trampolinePath = "tee/vm/_teevm"
+	trampolineCall = "_teevm.Run()"
+)
+
+func (v *VM) trampoline() interp.Exports {
+	return interp.Exports{trampolinePath + "/_teevm": {
+		"Run": reflect.ValueOf(func() { v.pending() }),
+	}}
+}
+
+// run f inside the interpreter within the limits, a panic of the program is returned as an error
+func (v *VM) run(f func()) error {
+	v.pending = f
+	defer func() { v.pending = nil }()
+	_, err := v.eval(trampolineCall)
+	return err
+}
+
+// eval evaluates src within the limits
+func (v *VM) eval(src string) (reflect.Value, error) {
+	ctx, cancel := context.WithCancelCause(context.Background())
+	defer cancel(nil)
+	if v.limits.Timeout > 0 {
+		var stop context.CancelFunc
+		ctx, stop = context.WithTimeoutCause(ctx, v.limits.Timeout, ErrTimeout)
+		defer stop()
+	}
+	if v.limits.Memory > 0 {
+		go watchMemory(ctx, cancel, v.limits.Memory)
+	}
+
+	res, err := v.interpreter.EvalWithContext(ctx, src)
+	if err != nil {
+		v.broken = true
+	}
+	if ctx.Err() != nil {
+		return res, context.Cause(ctx)
+	}
+	var p interp.Panic
+	if errors.As(err, &p) {
+		return res, fmt.Errorf("program panicked: %v", p.Value)
+	}
+	return res, err
+}
+
+// watchMemory cancels the execution when the heap grows by more than limit bytes.
+// the heap is shared with the TEE, so the growth is only an approximation of the memory used by the program.
+func watchMemory(ctx context.Context, cancel context.CancelCauseFunc, limit uint64) {
+	sample := []metrics.Sample{{Name: "/memory/classes/heap/objects:bytes"}}
+	metrics.Read(sample)
+	base := sample[0].Value.Uint64()
+
+	ticker := time.NewTicker(memoryCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			metrics.Read(sample)
+			if used := sample[0].Value.Uint64(); used > base && used-base > limit {
+				cancel(ErrMemoryLimit)
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package vm
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"reflect"
+	"sort"
+	"strings"
+	"testing/fstest"
+)
+
+const (
+	// FilesPackage is the host package giving programs the data files of their package:
+	//
+	//	import "tee/files"
+	//
+	//	files.Read(name string) ([]byte, error)   content of a file of the archive, by its path in the archive
+	FilesPackage = "tee/files"
+
+	singleFileName = "main.go" // name of the file of a program deployed as a single file
+	maxPackageSize = 16 << 20  // uncompressed size of a package archive
+)
+
+// Package is the code of a program, either a single Go file or a zip archive of a directory.
+// The Go files of the root of the archive form the main package, test files are ignored,
+// every other file is a data file.
+type Package struct {
+	Sources map[string][]byte
+	Files   map[string][]byte
+}
+
+// CodeError is an error of the user code, its message names the file and the line
+type CodeError struct {
+	Err error
+}
+
+func (e *CodeError) Error() string {
+	return e.Err.Error()
+}
+
+func codeErrorf(format string, a ...interface{}) error {
+	return &CodeError{Err: fmt.Errorf(format, a...)}
+}
+
+// ParsePackage reads the files of the code of a program
+func ParsePackage(code []byte) (*Package, error) {
+	if !bytes.HasPrefix(code, []byte("PK\x03\x04")) {
+		return &Package{Sources: map[string][]byte{singleFileName: code}, Files: map[string][]byte{}}, nil
+	}
+	r, err := zip.NewReader(bytes.NewReader(code), int64(len(code)))
+	if err != nil {
+		return nil, codeErrorf("invalid package archive: %v", err)
+	}
+
+	pkg := &Package{Sources: map[string][]byte{}, Files: map[string][]byte{}}
+	var size uint64
+	for _, f := range r.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		name := path.Clean(f.Name)
+		if path.IsAbs(name) || name == ".." || strings.HasPrefix(name, "../") {
+			return nil, codeErrorf("invalid file name in package: %q", f.Name)
+		}
+		size += f.UncompressedSize64
+		if size > maxPackageSize {
+			return nil, codeErrorf("package larger than %d bytes", maxPackageSize)
+		}
+		data, err := readZipFile(f)
+		if err != nil {
+			return nil, codeErrorf("failed to read %s: %v", name, err)
+		}
+
+		switch {
+		case strings.HasSuffix(name, "_test.go"):
+		case strings.HasSuffix(name, ".go") && path.Dir(name) != ".":
+			return nil, codeErrorf("%s: packages in subdirectories are not supported", name)
+		case strings.HasSuffix(name, ".go"):
+			pkg.Sources[name] = data
+		default:
+			pkg.Files[name] = data
+		}
+	}
+	if len(pkg.Sources) == 0 {
+		return nil, codeErrorf("package has no Go file")
+	}
+	return pkg, nil
+}
+
+func readZipFile(f *zip.File) ([]byte, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	// the declared size can not be trusted
+	data, err := io.ReadAll(io.LimitReader(rc, maxPackageSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxPackageSize {
+		return nil, fmt.Errorf("file larger than %d bytes", maxPackageSize)
+	}
+	return data, nil
+}
+
+// names of the Go files, sorted so that every TEE loads them in the same order
+func (p *Package) sourceNames() []string {
+	names := make([]string, 0, len(p.Sources))
+	for name := range p.Sources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// the Go files are served to yaegi from memory as the sources of the main package, in $GOPATH/src/main/main
+// where yaegi looks for the package "main" imported from the main package
+const (
+	sourceRoot  = "."
+	mainPackage = "main"
+	sourceDir   = "src/main/main/"
+)
+
+func (p *Package) sourceFS() fs.FS {
+	fsys := fstest.MapFS{}
+	for name, data := range p.Sources {
+		fsys[sourceDir+name] = &fstest.MapFile{Data: data, Mode: 0444}
+	}
+	return fsys
+}
+
+// sourceError keeps the error of the user code from an error of yaegi loading the package,
+// its positions name the file as in the archive
+func sourceError(msg string) string {
+	if _, after, ok := strings.Cut(msg, fmt.Sprintf("import %q error: ", mainPackage)); ok {
+		msg = after
+	}
+	return strings.ReplaceAll(msg, sourceDir, "")
+}
+
+// symbols of the files package
+func filesSymbols(p *Package) map[string]reflect.Value {
+	return map[string]reflect.Value{
+		"Read": reflect.ValueOf(func(name string) ([]byte, error) {
+			data, ok := p.Files[path.Clean(name)]
+			if !ok {
+				return nil, fmt.Errorf("file not found: %s", name)
+			}
+			// the program can not modify the package
+			return append([]byte{}, data...), nil
+		}),
+	}
+}
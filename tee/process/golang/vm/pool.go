@@ -0,0 +1,293 @@
+package vm
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"reflect"
+	"sort"
+	"sync"
+)
+
+const (
+	DefaultPoolPrograms = 64 // programs with idle interpreters, the least recently used is evicted
+	idlePerProgram      = 2  // idle interpreters kept per program
+)
+
+// Pool keeps initialized interpreters per code hash, so that repeated executions of a program skip
+// creating the interpreter and interpreting the code.
+//
+// A reused interpreter must behave exactly as a new one, otherwise TEEs would disagree:
+//   - the global variables are restored to a copy of their values after initialization
+//   - programs whose initialization read the context (rand, time, chain) or whose globals
+//     can not be copied (functions, channels, values of the standard library) are never pooled
+//   - an interpreter whose evaluation failed or was interrupted is dropped
+//   - the code hash identifies the code, so an entry never becomes stale
+type Pool struct {
+	mu       sync.Mutex
+	programs int
+	idle     map[[32]byte][]*VM
+	lru      *list.List // code hashes, most recently used first
+	elems    map[[32]byte]*list.Element
+}
+
+func NewPool(programs int) *Pool {
+	return &Pool{
+		programs: programs,
+		idle:     map[[32]byte][]*VM{},
+		lru:      list.New(),
+		elems:    map[[32]byte]*list.Element{},
+	}
+}
+
+// Get returns an idle interpreter of the code reset to the context, or a new one. a nil pool always creates one.
+func (p *Pool) Get(userCode []byte, ctx Context) (*VM, error) {
+	if p == nil {
+		return New(userCode, ctx)
+	}
+	hash := sha256.Sum256(userCode)
+	p.mu.Lock()
+	var v *VM
+	if vms := p.idle[hash]; len(vms) > 0 {
+		v = vms[len(vms)-1]
+		p.idle[hash] = vms[:len(vms)-1]
+	}
+	p.mu.Unlock()
+
+	if v != nil {
+		v.reset(ctx)
+		return v, nil
+	}
+	return New(userCode, ctx)
+}
+
+// Put returns an interpreter to the pool once it is no longer used
+func (p *Pool) Put(v *VM) {
+	if p == nil || v.broken || v.initial == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.idle[v.code]) >= idlePerProgram {
+		return
+	}
+	p.idle[v.code] = append(p.idle[v.code], v)
+	if e, ok := p.elems[v.code]; ok {
+		p.lru.MoveToFront(e)
+		return
+	}
+	p.elems[v.code] = p.lru.PushFront(v.code)
+	if p.lru.Len() > p.programs {
+		oldest := p.lru.Remove(p.lru.Back()).([32]byte)
+		delete(p.elems, oldest)
+		delete(p.idle, oldest)
+	}
+}
+
+// snapshot the globals after initialization, the VM is not reusable when they can not be restored
+func (v *VM) snapshot() {
+	// the values of the store are never modified in place, copying the map is enough
+	v.initialStore = map[string][]byte{}
+	for k, value := range v.store {
+		v.initialStore[k] = value
+	}
+	if v.contextUsed {
+		return
+	}
+	globals := v.interpreter.Globals()
+	if !copyable(globals) {
+		return
+	}
+	v.globals = globals
+	v.initial = copyGlobals(globals)
+}
+
+// reset the VM to its state after initialization, with a new context
+func (v *VM) reset(ctx Context) {
+	v.ctx = ctx
+	v.limits = ctx.Limits
+	v.rand.Seed(ctx.Seed)
+	v.store.replace(v.initialStore)
+	for name, value := range copyGlobals(v.initial) {
+		v.globals[name].Set(value)
+	}
+}
+
+// copy the globals together, so that the pointers and maps shared between them stay shared
+func copyGlobals(globals map[string]reflect.Value) map[string]reflect.Value {
+	seen := map[ref]reflect.Value{}
+	res := make(map[string]reflect.Value, len(globals))
+	for name, value := range globals {
+		res[name] = deepCopy(value, seen)
+	}
+	return res
+}
+
+// ref identifies a pointer or a map
+type ref struct {
+	t reflect.Type
+	p uintptr
+}
+
+// memory reachable from the globals
+type region struct {
+	start, end uintptr
+}
+
+// copyable reports whether deepCopy copies every value reachable from the globals, keeping the sharing between them.
+// pointers to the same value and shared maps are kept, any other overlap, such as a pointer into a slice, a field or
+// a global, or two slices sharing an array, can not be.
+func copyable(globals map[string]reflect.Value) bool {
+	w := &walker{seen: map[ref]bool{}}
+	for _, v := range globals {
+		if !v.CanAddr() {
+			return false
+		}
+		w.add(v.UnsafeAddr(), v.Type().Size())
+		if !w.walk(v) {
+			return false
+		}
+	}
+	sort.Slice(w.regions, func(i, j int) bool { return w.regions[i].start < w.regions[j].start })
+	for i := 1; i < len(w.regions); i++ {
+		if w.regions[i].start < w.regions[i-1].end {
+			return false
+		}
+	}
+	return true
+}
+
+type walker struct {
+	seen    map[ref]bool
+	regions []region
+}
+
+func (w *walker) add(start uintptr, size uintptr) {
+	if size > 0 {
+		w.regions = append(w.regions, region{start, start + size})
+	}
+}
+
+func (w *walker) walk(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Bool, reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64, reflect.Complex64, reflect.Complex128:
+		return true
+	case reflect.Func, reflect.Chan:
+		return v.IsNil()
+	case reflect.Pointer:
+		if v.IsNil() {
+			return true
+		}
+		r := ref{v.Type(), v.Pointer()}
+		if w.seen[r] {
+			return true
+		}
+		w.seen[r] = true
+		w.add(v.Pointer(), v.Type().Elem().Size())
+		return w.walk(v.Elem())
+	case reflect.Interface:
+		return v.IsNil() || w.walk(v.Elem())
+	case reflect.Slice:
+		if v.IsNil() {
+			return true
+		}
+		w.add(v.Pointer(), uintptr(v.Cap())*v.Type().Elem().Size())
+		// the elements past the length can be reached by reslicing
+		return w.walkElems(v.Slice(0, v.Cap()))
+	case reflect.Array:
+		return w.walkElems(v)
+	case reflect.Map:
+		if v.IsNil() {
+			return true
+		}
+		r := ref{v.Type(), v.Pointer()}
+		if w.seen[r] {
+			return true
+		}
+		w.seen[r] = true
+		iter := v.MapRange()
+		for iter.Next() {
+			if !w.walk(iter.Key()) || !w.walk(iter.Value()) {
+				return false
+			}
+		}
+		return true
+	case reflect.Struct:
+		// the fields of interpreted types are exported, those of the standard library may not be
+		for i := 0; i < v.NumField(); i++ {
+			if !v.Type().Field(i).IsExported() || !w.walk(v.Field(i)) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+func (w *walker) walkElems(v reflect.Value) bool {
+	for i := 0; i < v.Len(); i++ {
+		if !w.walk(v.Index(i)) {
+			return false
+		}
+	}
+	return true
+}
+
+// deepCopy copies a copyable value, seen maps the pointers and maps already copied to their copy
+func deepCopy(v reflect.Value, seen map[ref]reflect.Value) reflect.Value {
+	res := reflect.New(v.Type()).Elem()
+	switch v.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() {
+			return res
+		}
+		r := ref{v.Type(), v.Pointer()}
+		if c, ok := seen[r]; ok {
+			return c
+		}
+		c := reflect.New(v.Type().Elem())
+		seen[r] = c
+		c.Elem().Set(deepCopy(v.Elem(), seen))
+		return c
+	case reflect.Interface:
+		if !v.IsNil() {
+			res.Set(deepCopy(v.Elem(), seen))
+		}
+	case reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			res.Index(i).Set(deepCopy(v.Index(i), seen))
+		}
+	case reflect.Slice:
+		if v.IsNil() {
+			return res
+		}
+		res.Set(reflect.MakeSlice(v.Type(), v.Len(), v.Cap()))
+		full, fullRes := v.Slice(0, v.Cap()), res.Slice(0, v.Cap())
+		for i := 0; i < v.Cap(); i++ {
+			fullRes.Index(i).Set(deepCopy(full.Index(i), seen))
+		}
+	case reflect.Map:
+		if v.IsNil() {
+			return res
+		}
+		r := ref{v.Type(), v.Pointer()}
+		if c, ok := seen[r]; ok {
+			return c
+		}
+		res.Set(reflect.MakeMapWithSize(v.Type(), v.Len()))
+		seen[r] = res
+		iter := v.MapRange()
+		for iter.Next() {
+			res.SetMapIndex(deepCopy(iter.Key(), seen), deepCopy(iter.Value(), seen))
+		}
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			res.Field(i).Set(deepCopy(v.Field(i), seen))
+		}
+	default:
+		res.Set(v)
+	}
+	return res
+}
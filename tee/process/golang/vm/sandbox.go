@@ -0,0 +1,208 @@
+package vm
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"math/rand"
+	"path"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/traefik/yaegi/interp"
+	"github.com/traefik/yaegi/stdlib"
+)
+
+// Context is the deterministic environment of one deploy or execution, it is the same in every TEE
+type Context struct {
+	Caller         common.Address
+	ProgramAddress common.Address
+	BlockNumber    uint64
+	BlockTime      uint64 // returned by time.Now
+	Seed           int64  // seed of math/rand
+	Limits         Limits // time and memory budget, does not change the result of a program within it
+
+	// call a function of another golang program or a solidity program, nil when programs can not be called
+	Call         func(program common.Address, method string, args []byte) ([]byte, error)
+	CallSolidity func(program common.Address, input []byte) ([]byte, error)
+}
+
+// deterministic packages user programs may import
+var allowedPackages = []string{
+	"bytes",
+	"cmp",
+	"container/heap",
+	"container/list",
+	"container/ring",
+	"crypto/hmac",
+	"crypto/sha256",
+	"crypto/sha512",
+	"encoding/base64",
+	"encoding/binary",
+	"encoding/hex",
+	"encoding/json",
+	"errors",
+	"fmt",
+	"hash",
+	"hash/crc32",
+	"hash/fnv",
+	"maps",
+	"math",
+	"math/big",
+	"math/bits",
+	"math/rand",
+	"regexp",
+	"slices",
+	"sort",
+	"strconv",
+	"strings",
+	"time",
+	"unicode",
+	"unicode/utf16",
+	"unicode/utf8",
+}
+
+// generic packages are interpreted from source by yaegi, they have no symbols
+var genericPackages = map[string]bool{"cmp": true, "maps": true, "slices": true}
+
+// symbols of allowed packages that access the host or the wall clock.
+// the time zone of programs is UTC: the functions returning local times are replaced in symbols and the Local
+// method of time.Time is rejected by check. only the zone name of a time decoded from text with the offset of the
+// host zone, e.g. by json.Unmarshal, may still differ between TEEs.
+// fmt printing and scanning are redirected by yaegi to the stdio of the interpreter, which are discarded
+var deniedSymbols = map[string][]string{
+	"time": {"After", "AfterFunc", "Local", "LoadLocation", "LoadLocationFromTZData", "NewTicker", "NewTimer", "Sleep", "Tick"},
+}
+
+// symbols returns the allowed standard library, with math/rand using r and time.Now returning the block time, and the host packages.
+// the symbols read the context when they are called and call use, so that the VM knows whether its initialization depends on the context.
+func symbols(ctx *Context, r *rand.Rand, use func()) interp.Exports {
+	// wrappers of the interfaces of the standard library
+	exports := interp.Exports{".": stdlib.Symbols["."]}
+	for _, pkg := range allowedPackages {
+		if genericPackages[pkg] {
+			continue
+		}
+		key := pkg + "/" + path.Base(pkg)
+		denied := map[string]bool{}
+		for _, name := range deniedSymbols[pkg] {
+			denied[name] = true
+		}
+		syms := map[string]reflect.Value{}
+		for name, value := range stdlib.Symbols[key] {
+			if !denied[name] {
+				syms[name] = value
+			}
+		}
+		exports[key] = syms
+	}
+
+	// the global functions of math/rand use the source seeded by the TEE
+	for name, value := range map[string]interface{}{
+		"ExpFloat64":  r.ExpFloat64,
+		"Float32":     r.Float32,
+		"Float64":     r.Float64,
+		"Int":         r.Int,
+		"Int31":       r.Int31,
+		"Int31n":      r.Int31n,
+		"Int63":       r.Int63,
+		"Int63n":      r.Int63n,
+		"Intn":        r.Intn,
+		"NormFloat64": r.NormFloat64,
+		"Perm":        r.Perm,
+		"Read":        r.Read,
+		"Seed":        r.Seed,
+		"Shuffle":     r.Shuffle,
+		"Uint32":      r.Uint32,
+		"Uint64":      r.Uint64,
+	} {
+		exports["math/rand/rand"][name] = hook(value, use)
+	}
+
+	// the wall clock is replaced by the block time
+	now := func() time.Time {
+		use()
+		return time.Unix(int64(ctx.BlockTime), 0).UTC()
+	}
+	exports["time/time"]["Now"] = reflect.ValueOf(now)
+	exports["time/time"]["Since"] = reflect.ValueOf(func(t time.Time) time.Duration { return now().Sub(t) })
+	exports["time/time"]["Until"] = reflect.ValueOf(func(t time.Time) time.Duration { return t.Sub(now()) })
+	exports["time/time"]["Unix"] = reflect.ValueOf(func(sec int64, nsec int64) time.Time { return time.Unix(sec, nsec).UTC() })
+	exports["time/time"]["UnixMilli"] = reflect.ValueOf(func(msec int64) time.Time { return time.UnixMilli(msec).UTC() })
+	exports["time/time"]["UnixMicro"] = reflect.ValueOf(func(usec int64) time.Time { return time.UnixMicro(usec).UTC() })
+	exports["time/time"]["Parse"] = reflect.ValueOf(func(layout string, value string) (time.Time, error) {
+		return time.ParseInLocation(layout, value, time.UTC)
+	})
+
+	exports[ChainPackage+"/chain"] = chainSymbols(ctx, use)
+	return exports
+}
+
+// hook returns a function calling use before fn
+func hook(fn interface{}, use func()) reflect.Value {
+	f := reflect.ValueOf(fn)
+	return reflect.MakeFunc(f.Type(), func(args []reflect.Value) []reflect.Value {
+		use()
+		return f.Call(args)
+	})
+}
+
+// check parses the files and rejects imports outside the allowlist, goroutines, whose scheduling is not deterministic,
+// select statements, which pick a random ready case, and the Local method of time.Time.
+// the order of a range over a map is random too, it can not be told from the syntax: programs must sort the keys,
+// e.g. with slices.Sorted(maps.Keys(m)), before depending on the order. fmt and encoding/json sort map keys.
+func check(pkg *Package) error {
+	allowed := map[string]bool{}
+	for _, p := range allowedPackages {
+		allowed[p] = true
+	}
+	for _, p := range hostPackages {
+		allowed[p] = true
+	}
+
+	fset := token.NewFileSet()
+	for _, name := range pkg.sourceNames() {
+		file, err := parser.ParseFile(fset, name, pkg.Sources[name], parser.SkipObjectResolution)
+		if err != nil {
+			return codeErrorf("failed to parse user code: %v", err)
+		}
+		if file.Name.Name != "main" {
+			pos := fset.Position(file.Package)
+			return codeErrorf("%s:%d: package %s, expected main", pos.Filename, pos.Line, file.Name.Name)
+		}
+		for _, imp := range file.Imports {
+			pos := fset.Position(imp.Pos())
+			p, err := strconv.Unquote(imp.Path.Value)
+			if err != nil {
+				return codeErrorf("%s:%d: invalid import: %v", pos.Filename, pos.Line, err)
+			}
+			if !allowed[p] {
+				return codeErrorf("%s:%d: forbidden import %q", pos.Filename, pos.Line, p)
+			}
+		}
+
+		ast.Inspect(file, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.GoStmt:
+				pos := fset.Position(n.Pos())
+				err = codeErrorf("%s:%d: goroutines are not allowed", pos.Filename, pos.Line)
+			case *ast.SelectStmt:
+				pos := fset.Position(n.Pos())
+				err = codeErrorf("%s:%d: select statements are not allowed", pos.Filename, pos.Line)
+			case *ast.SelectorExpr:
+				// the Local method of time.Time reads the time zone of the host
+				if n.Sel.Name == "Local" {
+					pos := fset.Position(n.Sel.Pos())
+					err = codeErrorf("%s:%d: Local is not allowed, the time zone of programs is UTC", pos.Filename, pos.Line)
+				}
+			}
+			return err == nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,119 @@
+package vm
+
+import (
+	"encoding/binary"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// StorePackage is the host package giving programs a key-value store persisted by the TEE as their states:
+//
+//	import "tee/store"
+//
+//	store.Get(key string) ([]byte, bool)
+//	store.Set(key string, value []byte)
+//	store.Delete(key string)
+//	store.Keys(prefix string) []string   keys starting with prefix, sorted
+//
+// A program defining GetStates and SetStates persists its states itself, the store of any other program is its states.
+// Defining only one of them is rejected, see New.
+const StorePackage = "tee/store"
+
+// store of a program, values are copied in and out so that the program can not modify it through a slice
+type store map[string][]byte
+
+func (s store) symbols() map[string]reflect.Value {
+	return map[string]reflect.Value{
+		"Get": reflect.ValueOf(func(key string) ([]byte, bool) {
+			value, ok := s[key]
+			if !ok {
+				return nil, false
+			}
+			return append([]byte{}, value...), true
+		}),
+		"Set": reflect.ValueOf(func(key string, value []byte) {
+			s[key] = append([]byte{}, value...)
+		}),
+		"Delete": reflect.ValueOf(func(key string) {
+			delete(s, key)
+		}),
+		"Keys": reflect.ValueOf(func(prefix string) []string {
+			keys := []string{}
+			for _, k := range s.keys() {
+				if strings.HasPrefix(k, prefix) {
+					keys = append(keys, k)
+				}
+			}
+			return keys
+		}),
+	}
+}
+
+func (s store) keys() []string {
+	keys := make([]string, 0, len(s))
+	for k := range s {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// replace the content of the store, the symbols keep referring to it
+func (s store) replace(entries map[string][]byte) {
+	for k := range s {
+		delete(s, k)
+	}
+	for k, v := range entries {
+		s[k] = append([]byte{}, v...)
+	}
+}
+
+// encode the store canonically: the entries sorted by key, each as the uvarint length of the key, the key,
+// the uvarint length of the value and the value. The same entries always give the same bytes.
+func (s store) encode() []byte {
+	var res []byte
+	for _, k := range s.keys() {
+		res = binary.AppendUvarint(res, uint64(len(k)))
+		res = append(res, k...)
+		res = binary.AppendUvarint(res, uint64(len(s[k])))
+		res = append(res, s[k]...)
+	}
+	return res
+}
+
+// decode states encoded by encode, only the canonical encoding is accepted
+func decodeStore(states []byte) (store, error) {
+	s := store{}
+	prev := ""
+	for len(states) > 0 {
+		key, rest, err := readEntry(states)
+		if err != nil {
+			return nil, fmt.Errorf("invalid key: %v", err)
+		}
+		value, rest, err := readEntry(rest)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value of %q: %v", key, err)
+		}
+		if len(s) > 0 && string(key) <= prev {
+			return nil, fmt.Errorf("keys not sorted: %q after %q", key, prev)
+		}
+		prev = string(key)
+		s[prev] = value
+		states = rest
+	}
+	return s, nil
+}
+
+func readEntry(b []byte) ([]byte, []byte, error) {
+	n, size := binary.Uvarint(b)
+	if size <= 0 || size != len(binary.AppendUvarint(nil, n)) {
+		return nil, nil, fmt.Errorf("invalid length")
+	}
+	b = b[size:]
+	if n > uint64(len(b)) {
+		return nil, nil, fmt.Errorf("length %d larger than the %d bytes left", n, len(b))
+	}
+	return b[:n:n], b[n:], nil
+}
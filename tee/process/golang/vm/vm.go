@@ -1,63 +1,284 @@
 package vm
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
 	"fmt"
-	"strings"
+	"go/token"
+	"io"
+	"math/rand"
+	"reflect"
 
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/traefik/yaegi/interp"
-	"github.com/traefik/yaegi/stdlib"
 )
 
-var (
+// VM is a yaegi interpreter loaded with one user program
+type VM struct {
 	interpreter *interp.Interpreter
-)
+	ctx         Context
+	limits      Limits
+	pending     func() // call run by the trampoline
+	rand        *rand.Rand
+	store       store
 
-// InitializeInterpreter initializes the yaegi interpreter
-func InitializeInterpreter(userCode []byte) error {
-	interpreter = interp.New(interp.Options{})
-	// Import Go standard library
-	interpreter.Use(stdlib.Symbols)
+	// reuse of the interpreter, see Pool
+	code         [32]byte // hash of the user code
+	contextUsed  bool     // the program read the context, checked after its initialization
+	initial      map[string]reflect.Value
+	globals      map[string]reflect.Value
+	initialStore map[string][]byte
+	broken       bool // an evaluation was interrupted, the interpreter can not be reused
+}
 
-	// dynamic interpret user code
-	_, err := interpreter.Eval(string(userCode))
+// New initializes the yaegi interpreter, user code only has access to the deterministic part of the standard library.
+// userCode is a single Go file or a package archive, see ParsePackage.
+func New(userCode []byte, ctx Context) (*VM, error) {
+	pkg, err := ParsePackage(userCode)
 	if err != nil {
-		return fmt.Errorf("failed to interpret user code: %v", err)
+		return nil, err
 	}
-	return nil
+	err = check(pkg)
+	if err != nil {
+		return nil, err
+	}
+	// user code can not write to the logs of the TEE nor read its input.
+	// the Go files are loaded from memory as the main package, so that yaegi compiles them together.
+	interpreter := interp.New(interp.Options{
+		Stdin:                bytes.NewReader(nil),
+		Stdout:               io.Discard,
+		Stderr:               io.Discard,
+		GoPath:               sourceRoot,
+		SourcecodeFilesystem: pkg.sourceFS(),
+	})
+	v := &VM{
+		interpreter: interpreter,
+		ctx:         ctx,
+		limits:      ctx.Limits,
+		rand:        rand.New(rand.NewSource(ctx.Seed)),
+		store:       store{},
+		code:        sha256.Sum256(userCode),
+	}
+	// Import the allowed part of the Go standard library
+	err = interpreter.Use(symbols(&v.ctx, v.rand, func() { v.contextUsed = true }))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load symbols: %v", err)
+	}
+	err = interpreter.Use(interp.Exports{FilesPackage + "/files": filesSymbols(pkg)})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load symbols: %v", err)
+	}
+	err = interpreter.Use(interp.Exports{StorePackage + "/store": v.store.symbols()})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load symbols: %v", err)
+	}
+	err = interpreter.Use(v.trampoline())
+	if err != nil {
+		return nil, fmt.Errorf("failed to load symbols: %v", err)
+	}
+
+	// dynamic interpret user code, package initialization is also bounded
+	_, err = v.eval(fmt.Sprintf("import _ %q", mainPackage))
+	if IsLimit(err) {
+		return nil, fmt.Errorf("failed to interpret user code: %w", err)
+	}
+	if err != nil {
+		return nil, codeErrorf("failed to interpret user code: %s", sourceError(err.Error()))
+	}
+	_, err = interpreter.Eval(fmt.Sprintf("import %q", trampolinePath))
+	if err != nil {
+		return nil, fmt.Errorf("failed to import trampoline: %v", err)
+	}
+	// the states written by one of them could not be read by the other
+	_, errGet := v.lookup("GetStates")
+	_, errSet := v.lookup("SetStates")
+	if (errGet == nil) != (errSet == nil) {
+		return nil, codeErrorf("a program defining GetStates or SetStates must define both")
+	}
+	v.snapshot()
+	return v, nil
 }
 
-func SetStates(states []byte) error {
+// SetCaller changes the caller returned by chain.Caller for the next calls
+func (v *VM) SetCaller(caller common.Address) {
+	v.ctx.Caller = caller
+}
+
+// SetStates loads the states of the program, into its store unless it defines SetStates
+func (v *VM) SetStates(states []byte) error {
+	if _, err := v.lookup("SetStates"); err != nil {
+		s, err := decodeStore(states)
+		if err != nil {
+			return fmt.Errorf("failed to decode store: %v", err)
+		}
+		v.store.replace(s)
+		return nil
+	}
 	// set the state
-	_, err := CallMethod("SetStates", fmt.Sprintf("[]byte(`%s`)", string(states)))
+	_, err := v.call("SetStates", []reflect.Value{reflect.ValueOf(states)})
 	if err != nil {
-		return fmt.Errorf("failed to call SetStates: %v", err)
+		return fmt.Errorf("failed to call SetStates: %w", err)
 	}
 
 	return nil
 }
 
-// SaveState saves the current state to a JSON string
-func GetStates() ([]byte, error) {
+// GetStates returns the current states of the program, the encoding of its store unless it defines GetStates
+func (v *VM) GetStates() ([]byte, error) {
+	if _, err := v.lookup("GetStates"); err != nil {
+		return v.store.encode(), nil
+	}
 	// get the current state
-	result, err := CallMethod("GetStates", "")
+	results, err := v.call("GetStates", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call GetStates: %w", err)
+	}
+	if len(results) != 1 {
+		return nil, fmt.Errorf("GetStates must return one value")
+	}
+	states, ok := results[0].Interface().([]byte)
+	if !ok {
+		return nil, fmt.Errorf("GetStates returned %v instead of []byte", results[0].Type())
+	}
+	return states, nil
+}
+
+// InteractContracts returns the programs declared by the optional GetInteractContracts function, the only ones the program can call
+func (v *VM) InteractContracts() ([]common.Address, error) {
+	if _, err := v.lookup("GetInteractContracts"); err != nil {
+		return nil, nil
+	}
+	results, err := v.call("GetInteractContracts", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call GetInteractContracts: %w", err)
+	}
+	if len(results) != 1 {
+		return nil, fmt.Errorf("GetInteractContracts must return one value")
+	}
+	programs, ok := results[0].Interface().([]string)
+	if !ok {
+		return nil, fmt.Errorf("GetInteractContracts returned %v instead of []string", results[0].Type())
+	}
+	addresses := make([]common.Address, len(programs))
+	for i, p := range programs {
+		if !common.IsHexAddress(p) {
+			return nil, fmt.Errorf("GetInteractContracts returned an invalid address: %q", p)
+		}
+		addresses[i] = common.HexToAddress(p)
+	}
+	return addresses, nil
+}
+
+// CallMethod calls an exported function of the program.
+// args is a JSON array with one element per parameter, decoded into the parameter types.
+// A single result is returned as is, several results as a slice, a non-nil trailing error fails the call.
+func (v *VM) CallMethod(methodName string, args []byte) (interface{}, error) {
+	fn, err := v.lookup(methodName)
+	if err != nil {
+		return nil, err
+	}
+	params, err := decodeArgs(fn.Type(), args)
 	if err != nil {
-		return nil, fmt.Errorf("failed to call GetStates: %v", err)
+		return nil, fmt.Errorf("failed to decode arguments of %s: %v", methodName, err)
 	}
-	return result.([]byte), nil
+	results, err := v.call(methodName, params)
+	if err != nil {
+		return nil, err
+	}
+
+	// a trailing error is not part of the result
+	if n := len(results); n > 0 && fn.Type().Out(n-1) == errorType {
+		if e := results[n-1].Interface(); e != nil {
+			return nil, fmt.Errorf("%s returned an error: %v", methodName, e)
+		}
+		results = results[:n-1]
+	}
+	switch len(results) {
+	case 0:
+		return nil, nil
+	case 1:
+		return results[0].Interface(), nil
+	}
+	values := make([]interface{}, len(results))
+	for i, r := range results {
+		values[i] = r.Interface()
+	}
+	return values, nil
 }
 
-// CallMethod calls a method in the interpreted code
-func CallMethod(methodName string, arg string) (interface{}, error) {
-	// construct the expression to call the method
-	arg = strings.Trim(arg, "\"")
-	expr := fmt.Sprintf("%s(%s)", methodName, arg)
-	// print("expr: ", expr, "\n")
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
 
-	// interpret the expression
-	result, err := interpreter.Eval(expr)
+// lookup an exported function of the main package, the name is checked so that no expression is evaluated
+func (v *VM) lookup(name string) (reflect.Value, error) {
+	if !token.IsIdentifier(name) || !token.IsExported(name) {
+		return reflect.Value{}, fmt.Errorf("invalid method name: %q", name)
+	}
+	fn, err := v.interpreter.Eval("main." + name)
 	if err != nil {
-		return nil, fmt.Errorf("failed to call method %s: %v", methodName, err)
+		return reflect.Value{}, fmt.Errorf("method %s not found: %v", name, err)
 	}
+	if fn.Kind() != reflect.Func {
+		return reflect.Value{}, fmt.Errorf("%s is not a function", name)
+	}
+	return fn, nil
+}
 
-	return result.Interface(), nil
+// call a function of the program with the given arguments
+func (v *VM) call(name string, params []reflect.Value) ([]reflect.Value, error) {
+	fn, err := v.lookup(name)
+	if err != nil {
+		return nil, err
+	}
+	t := fn.Type()
+	if !t.IsVariadic() && len(params) != t.NumIn() {
+		return nil, fmt.Errorf("%s takes %d arguments, got %d", name, t.NumIn(), len(params))
+	}
+	for i, p := range params {
+		if !p.Type().AssignableTo(paramType(t, i)) {
+			return nil, fmt.Errorf("argument %d of %s must be %v", i, name, paramType(t, i))
+		}
+	}
+	var results []reflect.Value
+	err = v.run(func() { results = fn.Call(params) })
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// decode the JSON array of arguments into values of the parameter types
+func decodeArgs(t reflect.Type, args []byte) ([]reflect.Value, error) {
+	var raw []json.RawMessage
+	if len(bytes.TrimSpace(args)) != 0 {
+		err := json.Unmarshal(args, &raw)
+		if err != nil {
+			return nil, fmt.Errorf("arguments must be a JSON array: %v", err)
+		}
+	}
+	if len(raw) < t.NumIn() && !(t.IsVariadic() && len(raw) >= t.NumIn()-1) {
+		return nil, fmt.Errorf("expected %d arguments, got %d", t.NumIn(), len(raw))
+	}
+	if len(raw) > t.NumIn() && !t.IsVariadic() {
+		return nil, fmt.Errorf("expected %d arguments, got %d", t.NumIn(), len(raw))
+	}
+
+	params := make([]reflect.Value, len(raw))
+	for i, r := range raw {
+		p := reflect.New(paramType(t, i))
+		err := json.Unmarshal(r, p.Interface())
+		if err != nil {
+			return nil, fmt.Errorf("argument %d: %v", i, err)
+		}
+		params[i] = p.Elem()
+	}
+	return params, nil
+}
+
+// type of the i-th argument, the extra arguments of a variadic function have the element type
+func paramType(t reflect.Type, i int) reflect.Type {
+	if t.IsVariadic() && i >= t.NumIn()-1 {
+		return t.In(t.NumIn() - 1).Elem()
+	}
+	return t.In(i)
 }
@@ -2,17 +2,26 @@ package process
 
 import (
 	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
 	"fmt"
-	"log"
 	"math/big"
+	"time"
 
 	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/common"
-	"github.com/ethereum/go-ethereum/core/types"
+	"google.golang.org/protobuf/proto"
 
 	"tee/help"
 	"tee/key"
-	"tee/process/cache"
+	"tee/node"
+	"tee/process/evm"
+	"tee/process/golang/vm"
+	pb "tee/proto"
+	"tee/txmgr"
 	"tee/utils"
 )
 
@@ -20,125 +29,259 @@ import (
 //		FuncName string          `json:"funcName"`
 //		Args     json.RawMessage `json:"args"`
 //	}
-var nonce uint64 = 0
+//
+// Process executes the events, an error means the events must be processed again, e.g. the RPC failed
+func Process(n *node.Node, events []map[string]interface{}) ([]help.Output, error) {
+	// clear cache
+	defer n.Cache.ClearCache()
 
-func initNonce(address common.Address) {
-	client := help.Client
-	ctx := context.Background()
-	_nonce, err := client.PendingNonceAt(ctx, address)
-	if err != nil {
-		log.Fatalf("Failed to get nonce: %v", err)
+	outputs := []help.Output{}
+	for _, event := range events {
+		out, err := processEvent(n, event)
+		if err != nil {
+			return nil, err
+		}
+		outputs = append(outputs, out...)
 	}
-	nonce = _nonce
+	return outputs, nil
 }
 
-func Process(events []map[string]interface{}) []help.Output {
-	outputs := []help.Output{}
-	for _, event := range events {
-		eventName := event["eventName"].(string)
-		var _outputs []help.Output
-
-		switch eventName {
-		case "Deploy":
-			println("Deploy")
-			_outputs = Deploy(event)
-		case "Execution":
-			println("Execution")
-			_outputs = Execute(event)
-		default:
-			continue
+// Failure is the error of one event, only Msg is published on chain since Err may contain private data
+type Failure struct {
+	Msg     string
+	Err     error
+	GasUsed uint64 // gas used by a failed execution, returned to the caller with Msg
+	Detail  string // private detail of Msg, e.g. the errors of the code, never published without encryption
+}
+
+// revert of a solidity program causing the failure, returned to the caller in the encrypted result only
+func (f *Failure) revert() *evm.RevertError {
+	var r *evm.RevertError
+	if errors.As(f.Err, &r) {
+		return r
+	}
+	return nil
+}
+
+func (f *Failure) Unwrap() error {
+	return f.Err
+}
+
+func (f *Failure) Error() string {
+	if f.Err == nil {
+		return f.Msg
+	}
+	return fmt.Sprintf("%s: %v", f.Msg, f.Err)
+}
+
+func fail(msg string, err error) error {
+	return &Failure{Msg: msg, Err: err}
+}
+
+// process one event, a failure or panic discards its changes and is reported by an error output
+func processEvent(n *node.Node, event map[string]interface{}) (outputs []help.Output, err error) {
+	snapshot := n.OCS.Snapshot()
+	cached := n.Cache.Copy()
+	defer func() {
+		if r := recover(); r != nil {
+			n.OCS.RevertToSnapshot(snapshot)
+			n.Cache.Restore(cached)
+			outputs, err = errorOutputs(n, event, fail("Failed to process event", fmt.Errorf("panic: %v", r)))
 		}
-		outputs = append(outputs, _outputs...)
+	}()
+
+	eventName, err := utils.Field[string](event, "eventName")
+	if err != nil {
+		fmt.Printf("Skipping malformed event: %v\n", err)
+		return nil, nil
 	}
+	switch eventName {
+	case "Deploy":
+		println("Deploy")
+		outputs, err = Deploy(n, event)
+	case "Execution":
+		println("Execution")
+		outputs, err = Execute(n, event)
+	case "ACL":
+		println("ACL")
+		outputs, err = ChangeACL(n, event)
+	default:
+		return nil, nil
+	}
+	if err != nil {
+		n.OCS.RevertToSnapshot(snapshot)
+		n.Cache.Restore(cached)
+		// the time and memory limits depend on the load of the host, a TEE exceeding them does not publish
+		// a failure that other TEEs may not reach, it processes the events again
+		if vm.IsLimit(err) {
+			return nil, fmt.Errorf("failed to process event within the limits: %v", err)
+		}
+		return errorOutputs(n, event, err)
+	}
+	return outputs, nil
+}
 
-	// clear cache
-	cache.ClearCache()
-	return outputs
+// seed of the random source of a program, derived from the management key so that every TEE uses the same unpredictable value
+func seed(n *node.Node, event map[string]interface{}, programAddress common.Address) int64 {
+	txHash, _ := utils.Field[string](event, "txHash")
+	logIndex, _ := utils.Field[uint](event, "logIndex")
+	mac := hmac.New(sha256.New, []byte(n.Keys.KeyMgt))
+	mac.Write(programAddress.Bytes())
+	mac.Write([]byte(txHash))
+	mac.Write(binary.BigEndian.AppendUint64(nil, uint64(logIndex)))
+	return int64(binary.BigEndian.Uint64(mac.Sum(nil)))
+}
+
+// limits of a Go program, the values of the user config are capped by the ceilings of the TEE, 0 selects the ceiling
+func limits(n *node.Node, timeoutMs uint32, memoryMB uint32) vm.Limits {
+	timeout := n.MaxTimeout
+	if t := time.Duration(timeoutMs) * time.Millisecond; t > 0 && (timeout == 0 || t < timeout) {
+		timeout = t
+	}
+	memory := n.MaxMemoryMB
+	if memoryMB > 0 && (memory == 0 || memoryMB < memory) {
+		memory = memoryMB
+	}
+	return vm.Limits{Timeout: timeout, Memory: uint64(memory) << 20}
+}
+
+// gas of an execution in the EVM, the value of the user config is capped by the ceiling of the TEE, 0 selects the ceiling
+func gasLimit(n *node.Node, gas uint64) uint64 {
+	if gas > 0 && (n.MaxGas == 0 || gas < n.MaxGas) {
+		return gas
+	}
+	return n.MaxGas
 }
 
-var lastNonce uint64 = 0
+// generate the error output of a failed event, nothing is output when the program is unknown.
+// an error means the program could not be checked, the event must be processed again.
+func errorOutputs(n *node.Node, event map[string]interface{}, err error) ([]help.Output, error) {
+	fmt.Printf("Failed to process event: %v\n", err)
+
+	data, e := utils.Field[map[string]interface{}](event, "data")
+	if e != nil {
+		return nil, nil
+	}
+	programAddress, e := utils.Field[common.Address](data, "programAddress")
+	if e != nil {
+		return nil, nil
+	}
+	// setResult reverts the whole output on an address without code, e.g. an account calling deploy directly
+	code, e := n.Chain.Client.CodeAt(context.Background(), programAddress, nil)
+	if e != nil {
+		return nil, fmt.Errorf("failed to get code of %v: %v", programAddress.Hex(), e)
+	}
+	if len(code) == 0 {
+		return nil, nil
+	}
+
+	msg := "Failed to process event"
+	detail := ""
+	var gasUsed uint64
+	var revert *evm.RevertError
+	var f *Failure
+	if errors.As(err, &f) {
+		msg = f.Msg
+		detail = f.Detail
+		gasUsed = f.GasUsed
+		revert = f.revert()
+	}
+	// encrypt the message with the result key of the sender, the generic message stays readable when the key cannot be decrypted
+	encryptedResultKey, _ := utils.Field[[]byte](data, "encryptedResultKey")
+	output := help.ErrorOutput(msg, programAddress, encryptedResultKey)
+	txPubKey, _ := utils.Field[[]byte](data, "transactionKey")
+	if len(encryptedResultKey) == 0 || len(txPubKey) == 0 {
+		return []help.Output{output}, nil
+	}
+	resultKey, e := n.Keys.ECIESDecrypt(encryptedResultKey, hex.EncodeToString(txPubKey))
+	if e != nil {
+		return []help.Output{output}, nil
+	}
+	if detail != "" {
+		msg = msg + ": " + detail
+	}
+	result := []byte(msg)
+	// the result of an execution or an ACL change is an envelope, also when it failed
+	if eventName, _ := utils.Field[string](event, "eventName"); eventName == "Execution" || eventName == "ACL" {
+		res := &pb.ExecutionResult{Error: msg, GasUsed: gasUsed}
+		if revert != nil {
+			res.Revert = revert.Data
+			res.RevertReason = revert.Reason
+		}
+		result, e = proto.Marshal(res)
+		if e != nil {
+			return []help.Output{output}, nil
+		}
+	}
+	encryptedMsg, e := key.EncryptAES(result, string(resultKey))
+	if e != nil {
+		return []help.Output{output}, nil
+	}
+	output.Result = encryptedMsg
+	return []help.Output{output}, nil
+}
 
-func SendOutputsToChain(account help.Account, outputs []help.Output, startBlock, endBlock uint64) error {
+func SendOutputsToChain(n *node.Node, outputs []help.Output, startBlock, endBlock uint64) (*txmgr.Tracked, error) {
 	// Create a shared context
 	ctx := context.Background()
-	client, parsedABI := help.Client, help.ParsedMCABI
+	client, parsedABI := n.Chain.Client, n.Chain.ParsedMCABI
 
 	// Get start and end block data
-	start, err := utils.GetBlock(startBlock)
+	start, err := utils.GetBlock(client, startBlock)
 	if err != nil {
-		return fmt.Errorf("failed to get start block: %v", err)
+		return nil, fmt.Errorf("failed to get start block: %v", err)
 	}
-	end, err := utils.GetBlock(endBlock)
+	end, err := utils.GetBlock(client, endBlock)
 	if err != nil {
-		return fmt.Errorf("failed to get end block: %v", err)
+		return nil, fmt.Errorf("failed to get end block: %v", err)
 	}
 
 	// Generate hash of outputs and sign it
-	hashOutputs, err := getHashOutputs(start, end, outputs)
+	hashOutputs, err := getHashOutputs(n.Chain, start, end, outputs)
 	if err != nil {
-		return fmt.Errorf("failed to hash outputs: %v", err)
+		return nil, fmt.Errorf("failed to hash outputs: %v", err)
 	}
-	signature, err := key.TEESign(hashOutputs)
+	signature, err := n.Keys.TEESign(hashOutputs)
 	if err != nil {
-		return fmt.Errorf("failed to sign outputs: %v", err)
+		return nil, fmt.Errorf("failed to sign outputs: %v", err)
 	}
 
 	// Encode transaction data with the contract ABI
 	outputsEncoded, err := parsedABI.Pack("output", start, end, outputs, signature)
 	if err != nil {
-		return fmt.Errorf("failed to encode outputs: %v", err)
-	}
-
-	// Get nonce and ensure it increases sequentially
-	if nonce == 0 {
-		initNonce(common.HexToAddress(account.Address))
+		return nil, fmt.Errorf("failed to encode outputs: %v", err)
 	}
 
-	// Suggest gas price and add 1 Gwei
-	gasPrice, err := client.SuggestGasPrice(ctx)
-	if err != nil {
-		return fmt.Errorf("failed to suggest gas price: %v", err)
-	}
-	gasPrice = new(big.Int).Add(gasPrice, big.NewInt(1000000000))
-
 	// Estimate gas limit and add a 5% buffer
-	MCAddress := common.HexToAddress(help.MCAddress)
+	MCAddress := common.HexToAddress(n.Chain.MCAddress)
 	msg := ethereum.CallMsg{
-		From:  common.HexToAddress(account.Address),
+		From:  common.HexToAddress(n.Account.Address),
 		To:    &MCAddress,
 		Value: big.NewInt(0),
 		Data:  outputsEncoded,
 	}
 	gasLimit, err := client.EstimateGas(ctx, msg)
 	if err != nil {
-		return fmt.Errorf("failed to estimate gas: %v", err)
+		return nil, fmt.Errorf("failed to estimate gas: %v", err)
 	}
 	gasLimit += gasLimit / 20
 
-	// Create and sign the transaction
-	tx := types.NewTransaction(nonce, MCAddress, big.NewInt(0), gasLimit, gasPrice, outputsEncoded)
-	nonce++
-	signedTx, err := help.SignTransaction(client, account.PrivateKey, tx)
+	// Send the transaction, the receipt is followed by the transaction manager
+	label := fmt.Sprintf("Outputs for blocks %v to %v", startBlock+1, endBlock)
+	tracked, err := n.Txs.Send(label, MCAddress, big.NewInt(0), gasLimit, outputsEncoded)
 	if err != nil {
-		return fmt.Errorf("failed to sign transaction: %v", err)
+		return nil, err
 	}
-
-	// Send the transaction
-	if err = client.SendTransaction(ctx, signedTx); err != nil {
-		return fmt.Errorf("failed to send transaction: %v", err)
-	}
-
-	fmt.Printf("Transaction sent! Tx hash: %s\n", signedTx.Hash().Hex())
-	return nil
+	return tracked, nil
 }
 
 // generate hash of the outputs by calling on-chain contract function for following signature
-func getHashOutputs(startBlock utils.BlockInfo, endBlock utils.BlockInfo, outputs []help.Output) ([]byte, error) {
-	parsedABI := help.ParsedMCABI
+func getHashOutputs(chain *help.Chain, startBlock utils.BlockInfo, endBlock utils.BlockInfo, outputs []help.Output) ([]byte, error) {
+	parsedABI := chain.ParsedMCABI
 
 	// get hash of outputs
 	var hashOutputs [32]byte
-	err := help.CallContractMethod(parsedABI, common.HexToAddress(help.MCAddress), "hashOutputs", []interface{}{startBlock, endBlock, outputs}, &hashOutputs)
+	err := chain.CallContractMethod(parsedABI, common.HexToAddress(chain.MCAddress), "hashOutputs", []interface{}{startBlock, endBlock, outputs}, &hashOutputs)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get hash of outputs: %v", err)
 	}
@@ -0,0 +1,455 @@
+package wasm
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Fuel metering: the program is rewritten before it is compiled so that each straight run of instructions,
+// ending at a control instruction (block, loop, if, else, end, br*, return, unreachable), first charges
+// its number of instructions to a fuel global. The fuel used then only depends on the program and its input,
+// every TEE stops a program at the same instruction.
+//
+// The charge of a run of cost c is:
+//
+//	global.get $fuel; i64.const c; i64.lt_u
+//	if; i64.const -1; global.set $fuel; unreachable; end
+//	global.get $fuel; i64.const c; i64.sub; global.set $fuel
+const (
+	fuelExport = "__racetee_fuel" // exported fuel global, read and written by the engine
+	outOfFuel  = math.MaxUint64   // value of the fuel global once a run found too little fuel
+)
+
+var wasmHeader = []byte{0x00, 'a', 's', 'm', 0x01, 0x00, 0x00, 0x00}
+
+// section ids
+const (
+	customSection = 0
+	importSection = 2
+	globalSection = 6
+	exportSection = 7
+	codeSection   = 10
+	tagSection    = 13
+)
+
+type section struct {
+	id   byte
+	body []byte
+}
+
+// meter returns the program charging the fuel global for every instruction it runs
+func meter(code []byte) ([]byte, error) {
+	if !bytes.HasPrefix(code, wasmHeader) {
+		return nil, errors.New("not a wasm module")
+	}
+	r := &reader{b: code, pos: len(wasmHeader)}
+	var sections []section
+	for r.more() {
+		id := r.byte()
+		size := r.u32()
+		sections = append(sections, section{id: id, body: r.bytes(int(size))})
+	}
+	if r.err != nil {
+		return nil, fmt.Errorf("malformed module: %v", r.err)
+	}
+
+	// the fuel global is defined after the imported and defined globals, no index of the program changes
+	imported, err := importedGlobals(find(sections, importSection))
+	if err != nil {
+		return nil, err
+	}
+	globals := find(sections, globalSection)
+	if globals == nil {
+		globals = insert(&sections, globalSection)
+	}
+	fuel, err := addFuelGlobal(globals, imported)
+	if err != nil {
+		return nil, err
+	}
+	exports := find(sections, exportSection)
+	if exports == nil {
+		exports = insert(&sections, exportSection)
+	}
+	err = addFuelExport(exports, fuel)
+	if err != nil {
+		return nil, err
+	}
+	if codes := find(sections, codeSection); codes != nil {
+		err = meterCode(codes, fuel)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	out := append([]byte{}, wasmHeader...)
+	for _, s := range sections {
+		out = append(out, s.id)
+		out = appendU32(out, uint32(len(s.body)))
+		out = append(out, s.body...)
+	}
+	return out, nil
+}
+
+func find(sections []section, id byte) *section {
+	for i := range sections {
+		if sections[i].id == id {
+			return &sections[i]
+		}
+	}
+	return nil
+}
+
+// rank of a section in the order of a module, the tag section (13) comes before the global section
+// and the data count section (12) before the code section
+func order(id byte) int {
+	switch id {
+	case tagSection:
+		return 2*globalSection - 1
+	case 12:
+		return 2*codeSection - 1
+	}
+	return 2 * int(id)
+}
+
+// insert an empty section at its place
+func insert(sections *[]section, id byte) *section {
+	i := 0
+	for ; i < len(*sections); i++ {
+		s := (*sections)[i]
+		if s.id != customSection && order(s.id) > order(id) {
+			break
+		}
+	}
+	*sections = append((*sections)[:i], append([]section{{id: id, body: appendU32(nil, 0)}}, (*sections)[i:]...)...)
+	return &(*sections)[i]
+}
+
+// importedGlobals returns the number of globals imported by the program
+func importedGlobals(imports *section) (uint32, error) {
+	if imports == nil {
+		return 0, nil
+	}
+	r := &reader{b: imports.body}
+	globals := uint32(0)
+	n := r.u32()
+	for i := uint32(0); i < n && r.err == nil; i++ {
+		r.name()
+		r.name()
+		switch kind := r.byte(); kind {
+		case 0x00: // function
+			r.u32()
+		case 0x01: // table
+			r.byte()
+			r.limits()
+		case 0x02: // memory
+			r.limits()
+		case 0x03: // global
+			r.byte()
+			r.byte()
+			globals++
+		default:
+			return 0, fmt.Errorf("unsupported import kind 0x%x", kind)
+		}
+	}
+	if r.err != nil {
+		return 0, fmt.Errorf("malformed import section: %v", r.err)
+	}
+	return globals, nil
+}
+
+// addFuelGlobal defines the mutable i64 fuel global and returns its index
+func addFuelGlobal(globals *section, imported uint32) (uint32, error) {
+	r := &reader{b: globals.body}
+	n := r.u32()
+	if r.err != nil {
+		return 0, fmt.Errorf("malformed global section: %v", r.err)
+	}
+	body := appendU32(nil, n+1)
+	body = append(body, globals.body[r.pos:]...)
+	// i64, mutable, initialized by i64.const 0, the engine sets the fuel before every call
+	body = append(body, 0x7E, 0x01, 0x42, 0x00, 0x0B)
+	globals.body = body
+	return imported + n, nil
+}
+
+// addFuelExport exports the fuel global
+func addFuelExport(exports *section, fuel uint32) error {
+	r := &reader{b: exports.body}
+	n := r.u32()
+	entries := r.pos
+	for i := uint32(0); i < n && r.err == nil; i++ {
+		if r.name() == fuelExport {
+			return fmt.Errorf("program exports %s", fuelExport)
+		}
+		r.byte()
+		r.u32()
+	}
+	if r.err != nil {
+		return fmt.Errorf("malformed export section: %v", r.err)
+	}
+	body := appendU32(nil, n+1)
+	body = append(body, exports.body[entries:]...)
+	body = appendU32(body, uint32(len(fuelExport)))
+	body = append(body, fuelExport...)
+	body = append(body, 0x03)
+	body = appendU32(body, fuel)
+	exports.body = body
+	return nil
+}
+
+// meterCode instruments the body of every function
+func meterCode(codes *section, fuel uint32) error {
+	r := &reader{b: codes.body}
+	n := r.u32()
+	out := appendU32(nil, n)
+	for i := uint32(0); i < n && r.err == nil; i++ {
+		size := r.u32()
+		body, err := meterBody(r.bytes(int(size)), fuel)
+		if err != nil {
+			return fmt.Errorf("function %d: %v", i, err)
+		}
+		out = appendU32(out, uint32(len(body)))
+		out = append(out, body...)
+	}
+	if r.err != nil {
+		return fmt.Errorf("malformed code section: %v", r.err)
+	}
+	codes.body = out
+	return nil
+}
+
+func meterBody(body []byte, fuel uint32) ([]byte, error) {
+	r := &reader{b: body}
+	// locals
+	groups := r.u32()
+	for i := uint32(0); i < groups && r.err == nil; i++ {
+		r.u32()
+		r.byte()
+	}
+	out := append([]byte{}, body[:r.pos]...)
+
+	start, cost := r.pos, uint64(0)
+	for r.more() && r.err == nil {
+		ends, err := r.instruction()
+		if err != nil {
+			return nil, err
+		}
+		cost++
+		if ends {
+			out = appendCharge(out, fuel, cost)
+			out = append(out, body[start:r.pos]...)
+			start, cost = r.pos, 0
+		}
+	}
+	if r.err != nil {
+		return nil, fmt.Errorf("malformed body: %v", r.err)
+	}
+	if cost > 0 {
+		return nil, errors.New("malformed body: missing end")
+	}
+	return out, nil
+}
+
+func appendCharge(out []byte, fuel uint32, cost uint64) []byte {
+	out = append(out, 0x23) // global.get
+	out = appendU32(out, fuel)
+	out = append(out, 0x42) // i64.const
+	out = appendS64(out, int64(cost))
+	out = append(out, 0x54, 0x04, 0x40) // i64.lt_u, if
+	out = append(out, 0x42, 0x7F)       // i64.const -1
+	out = append(out, 0x24)             // global.set
+	out = appendU32(out, fuel)
+	out = append(out, 0x00, 0x0B) // unreachable, end
+	out = append(out, 0x23)       // global.get
+	out = appendU32(out, fuel)
+	out = append(out, 0x42) // i64.const
+	out = appendS64(out, int64(cost))
+	out = append(out, 0x7D, 0x24) // i64.sub, global.set
+	out = appendU32(out, fuel)
+	return out
+}
+
+// instruction skips one instruction and reports whether it ends a run of instructions
+func (r *reader) instruction() (bool, error) {
+	op := r.byte()
+	switch {
+	case op == 0x00 || op == 0x05 || op == 0x0B || op == 0x0F: // unreachable, else, end, return
+		return true, nil
+	case op == 0x01 || op == 0x1A || op == 0x1B: // nop, drop, select
+	case op >= 0x02 && op <= 0x04: // block, loop, if
+		r.blockType()
+		return true, nil
+	case op == 0x0C || op == 0x0D: // br, br_if
+		r.u32()
+		return true, nil
+	case op == 0x0E: // br_table
+		n := r.u32()
+		for i := uint32(0); i <= n && r.err == nil; i++ {
+			r.u32()
+		}
+		return true, nil
+	case op == 0x10: // call
+		r.u32()
+	case op == 0x11: // call_indirect
+		r.u32()
+		r.u32()
+	case op == 0x1C: // select with types
+		r.bytes(int(r.u32()))
+	case op >= 0x20 && op <= 0x26: // local, global, table.get, table.set
+		r.u32()
+	case op >= 0x28 && op <= 0x3E: // load, store
+		r.memArg()
+	case op == 0x3F || op == 0x40: // memory.size, memory.grow
+		r.u32()
+	case op == 0x41 || op == 0x42: // i32.const, i64.const
+		r.s64()
+	case op == 0x43: // f32.const
+		r.bytes(4)
+	case op == 0x44: // f64.const
+		r.bytes(8)
+	case op >= 0x45 && op <= 0xC4: // numeric
+	case op == 0xD0: // ref.null
+		r.byte()
+	case op == 0xD1: // ref.is_null
+	case op == 0xD2: // ref.func
+		r.u32()
+	case op == 0xFC:
+		return false, r.miscInstruction()
+	default:
+		return false, fmt.Errorf("unsupported instruction 0x%x", op)
+	}
+	return false, nil
+}
+
+// saturating truncations, bulk memory and table instructions
+func (r *reader) miscInstruction() error {
+	switch op := r.u32(); {
+	case op <= 7: // trunc_sat
+	case op == 9 || op == 11 || op == 13 || (op >= 15 && op <= 17): // data.drop, memory.fill, elem.drop, table.grow/size/fill
+		r.u32()
+	case op == 8 || op == 10 || op == 12 || op == 14: // memory.init, memory.copy, table.init, table.copy
+		r.u32()
+		r.u32()
+	default:
+		return fmt.Errorf("unsupported instruction 0xfc %d", op)
+	}
+	return nil
+}
+
+// reader decodes the binary format, the first error is kept and stops the decoding
+type reader struct {
+	b   []byte
+	pos int
+	err error
+}
+
+func (r *reader) more() bool {
+	return r.err == nil && r.pos < len(r.b)
+}
+
+func (r *reader) fail(err error) {
+	if r.err == nil {
+		r.err = err
+	}
+	r.pos = len(r.b)
+}
+
+func (r *reader) byte() byte {
+	if r.pos >= len(r.b) {
+		r.fail(errors.New("unexpected end"))
+		return 0
+	}
+	b := r.b[r.pos]
+	r.pos++
+	return b
+}
+
+func (r *reader) bytes(n int) []byte {
+	if n < 0 || n > len(r.b)-r.pos {
+		r.fail(errors.New("unexpected end"))
+		return nil
+	}
+	b := r.b[r.pos : r.pos+n]
+	r.pos += n
+	return b
+}
+
+func (r *reader) u32() uint32 {
+	var v uint32
+	for shift := 0; shift < 35; shift += 7 {
+		b := r.byte()
+		v |= uint32(b&0x7F) << shift
+		if b&0x80 == 0 {
+			return v
+		}
+	}
+	r.fail(errors.New("integer too long"))
+	return 0
+}
+
+func (r *reader) s64() {
+	for i := 0; i < 10; i++ {
+		if r.byte()&0x80 == 0 {
+			return
+		}
+	}
+	r.fail(errors.New("integer too long"))
+}
+
+func (r *reader) name() string {
+	return string(r.bytes(int(r.u32())))
+}
+
+func (r *reader) limits() {
+	flags := r.byte()
+	if flags&0x04 != 0 {
+		r.fail(errors.New("64-bit memories are not supported"))
+		return
+	}
+	r.u32()
+	if flags&0x01 != 0 {
+		r.u32()
+	}
+}
+
+func (r *reader) blockType() {
+	if r.pos < len(r.b) {
+		switch r.b[r.pos] {
+		case 0x40, 0x7F, 0x7E, 0x7D, 0x7C, 0x7B, 0x70, 0x6F:
+			r.pos++
+			return
+		}
+	}
+	// index of a function type
+	r.s64()
+}
+
+func (r *reader) memArg() {
+	align := r.u32()
+	if align&0x40 != 0 {
+		// index of the memory
+		r.u32()
+	}
+	r.u32()
+}
+
+func appendU32(b []byte, v uint32) []byte {
+	for v >= 0x80 {
+		b = append(b, byte(v)|0x80)
+		v >>= 7
+	}
+	return append(b, byte(v))
+}
+
+func appendS64(b []byte, v int64) []byte {
+	for {
+		c := byte(v & 0x7F)
+		v >>= 7
+		if (v == 0 && c&0x40 == 0) || (v == -1 && c&0x40 != 0) {
+			return append(b, c)
+		}
+		b = append(b, c|0x80)
+	}
+}
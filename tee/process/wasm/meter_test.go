@@ -0,0 +1,175 @@
+package wasm
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+)
+
+// module builds a program of functions of type (i32) -> i32 without locals, the first one is exported as f.
+// a table holds every function at its index for call_indirect, the memory has 1 page and at most 4.
+func module(bodies ...[]byte) []byte {
+	n := uint32(len(bodies))
+	types := []byte{0x01, 0x60, 0x01, 0x7F, 0x01, 0x7F}
+	funcs := appendU32(nil, n)
+	elems := append([]byte{0x01, 0x00, 0x41, 0x00, 0x0B}, appendU32(nil, n)...)
+	codes := appendU32(nil, n)
+	for i, b := range bodies {
+		funcs = append(funcs, 0x00)
+		elems = appendU32(elems, uint32(i))
+		body := append([]byte{0x00}, b...)
+		codes = appendU32(codes, uint32(len(body)))
+		codes = append(codes, body...)
+	}
+	sections := []section{
+		{1, types},
+		{3, funcs},
+		{4, append([]byte{0x01, 0x70, 0x00}, appendU32(nil, n)...)},
+		{5, []byte{0x01, 0x01, 0x01, 0x04}},
+		{7, []byte{0x01, 0x01, 'f', 0x00, 0x00}},
+		{9, elems},
+		{codeSection, codes},
+	}
+	out := append([]byte{}, wasmHeader...)
+	for _, s := range sections {
+		out = append(out, s.id)
+		out = appendU32(out, uint32(len(s.body)))
+		out = append(out, s.body...)
+	}
+	return out
+}
+
+// run the metered program with the given fuel, it returns the result of f and the fuel used
+func run(t *testing.T, code []byte, fuel uint64, arg uint32) (uint32, uint64, error) {
+	t.Helper()
+	metered, err := meter(code)
+	if err != nil {
+		t.Fatalf("failed to meter program: %v", err)
+	}
+	ctx := context.Background()
+	runtime := wazero.NewRuntimeWithConfig(ctx, wazero.NewRuntimeConfigInterpreter())
+	defer runtime.Close(ctx)
+	mod, err := runtime.Instantiate(ctx, metered)
+	if err != nil {
+		t.Fatalf("failed to instantiate program: %v", err)
+	}
+	e := &Engine{fuel: fuel, left: fuel, global: mod.ExportedGlobal(fuelExport).(api.MutableGlobal)}
+	e.global.Set(fuel)
+	res, err := e.call(ctx, mod.ExportedFunction("f"), uint64(arg))
+	if err != nil {
+		return 0, e.FuelUsed(), err
+	}
+	return uint32(res[0]), e.FuelUsed(), nil
+}
+
+func TestMeter(t *testing.T) {
+	// each run of instructions costs its length, the control instruction ending it included
+	straight := module([]byte{
+		0x20, 0x00, // local.get 0
+		0x0B, // end: 2
+	})
+	// counts the argument down to 0
+	loop := module([]byte{
+		0x03, 0x40, // loop: 1
+		0x20, 0x00, 0x41, 0x01, 0x6B, 0x22, 0x00, // local.get 0, i32.const 1, i32.sub, local.tee 0
+		0x0D, 0x00, // br_if 0: 5 per iteration
+		0x0B,       // end: 1
+		0x20, 0x00, // local.get 0
+		0x0B, // end: 2
+	})
+	// returns 10 for 0 and 20 for any other argument, a branch skips the end of its block
+	brTable := module([]byte{
+		0x02, 0x40, // block: 1
+		0x02, 0x40, // block: 1
+		0x20, 0x00, 0x0E, 0x01, 0x00, 0x01, // local.get 0, br_table 0 1: 2
+		0x0B,                   // end, never reached
+		0x41, 0x0A, 0x01, 0x0F, // i32.const 10, nop, return: 3
+		0x0B,       // end, never reached
+		0x41, 0x14, // i32.const 20
+		0x0B, // end: 2
+	})
+	// calls the function 1 through the table, which adds 1
+	callIndirect := module([]byte{
+		0x20, 0x00, 0x41, 0x01, // local.get 0, i32.const 1
+		0x11, 0x00, 0x00, // call_indirect type 0 table 0
+		0x0B, // end: 4
+	}, []byte{
+		0x20, 0x00, 0x41, 0x01, 0x6A, // local.get 0, i32.const 1, i32.add
+		0x0B, // end: 4
+	})
+	// grows the memory by the argument, returns the previous size or -1
+	memoryGrow := module([]byte{
+		0x20, 0x00, // local.get 0
+		0x40, 0x00, // memory.grow 0
+		0x0B, // end: 3
+	})
+
+	tests := []struct {
+		name   string
+		code   []byte
+		fuel   uint64
+		arg    uint32
+		result uint32
+		used   uint64
+		err    error
+	}{
+		{"straight", straight, 10, 7, 7, 2, nil},
+		{"straight exact fuel", straight, 2, 7, 7, 2, nil},
+		{"straight out of fuel", straight, 1, 7, 0, 1, ErrOutOfFuel},
+		{"loop", loop, 100, 3, 0, 4 + 5*3, nil},
+		{"loop exact fuel", loop, 19, 3, 0, 19, nil},
+		{"loop out of fuel", loop, 18, 3, 0, 18, ErrOutOfFuel},
+		{"br_table first label", brTable, 100, 0, 10, 7, nil},
+		{"br_table default", brTable, 100, 5, 20, 6, nil},
+		{"call_indirect", callIndirect, 100, 41, 42, 8, nil},
+		{"call_indirect out of fuel in the callee", callIndirect, 7, 41, 0, 7, ErrOutOfFuel},
+		{"memory.grow", memoryGrow, 100, 1, 1, 3, nil},
+		{"memory.grow above the maximum", memoryGrow, 100, 10, 0xFFFFFFFF, 3, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, used, err := run(t, tt.code, tt.fuel, tt.arg)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("got error %v, want %v", err, tt.err)
+			}
+			if err == nil && result != tt.result {
+				t.Errorf("got result %d, want %d", result, tt.result)
+			}
+			if used != tt.used {
+				t.Errorf("used %d fuel, want %d", used, tt.used)
+			}
+		})
+	}
+}
+
+func TestMeterRejects(t *testing.T) {
+	tests := []struct {
+		name string
+		body []byte
+	}{
+		{"simd v128.const", []byte{0xFD, 0x0C}},
+		{"simd i32x4.add", []byte{0xFD, 0xAE, 0x01}},
+		{"return_call", []byte{0x12, 0x00}},
+		{"return_call_indirect", []byte{0x13, 0x00, 0x00}},
+		{"try", []byte{0x06, 0x40}},
+		{"catch", []byte{0x07, 0x00}},
+		{"throw", []byte{0x08, 0x00}},
+		{"rethrow", []byte{0x09, 0x00}},
+		{"throw_ref", []byte{0x0A}},
+		{"delegate", []byte{0x18, 0x00}},
+		{"catch_all", []byte{0x19}},
+		{"try_table", []byte{0x1F, 0x40, 0x00}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := meter(module(append(tt.body, 0x0B)))
+			if err == nil || !strings.Contains(err.Error(), "unsupported instruction") {
+				t.Fatalf("got error %v, want an unsupported instruction", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,298 @@
+// Package wasm runs privacy programs compiled to WebAssembly (e.g. Rust or TinyGo) with wazero.
+//
+// A program must export:
+//
+//	memory
+//	alloc(size i32) i32               buffer for the data written by the TEE
+//	get_states() i64                  states to store, packed as ptr<<32 | len
+//	set_states(ptr i32, len i32)      restore the stored states
+//	execute(ptr i32, len i32) i64     run the input, returns the result packed as ptr<<32 | len
+//	deploy()                          optional, called once when the program is deployed
+//
+// and may import from the "env" module:
+//
+//	caller(ptr i32)                   writes the 20 bytes caller address
+//	program_address(ptr i32)          writes the 20 bytes program address
+//	block_number() i64
+//	block_time() i64
+//	fuel_left() i64
+//
+// Every instruction consumes one unit of fuel and every host function call hostCost more, see meter.go.
+// The execution aborts when the fuel, the gas limit of the program, runs out: it is the only limit on the time
+// of a program, so that every TEE stops it at the same instruction. The memory is limited by the memory limit of
+// the program. No WASI is provided, so programs have no access to clocks, randomness or files.
+package wasm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+)
+
+const (
+	DefaultFuel = 10000000 // fuel of one deploy or execution
+	hostCost    = 10       // extra fuel consumed by each host function call
+
+	DefaultMemory = 16 << 20 // memory of a program without memory limit
+	pageSize      = 1 << 16
+	maxPages      = 1 << 16 // 4GB, the memory of 32-bit wasm
+	hostModule    = "env"
+	memoryExport  = "memory"
+)
+
+var ErrOutOfFuel = errors.New("out of fuel")
+
+// Engine runs one wasm program
+type Engine struct {
+	fuel           uint64
+	left           uint64
+	pages          uint32            // memory limit
+	global         api.MutableGlobal // fuel global of the running program, see meter
+	programAddress common.Address
+	callerAddress  common.Address
+	blockNumber    *big.Int
+	blockTime      uint64
+}
+
+// New returns an engine for one deploy or execution, a zero fuel or memory selects the default
+func New(fuel uint64, memory uint64) *Engine {
+	if fuel == 0 {
+		fuel = DefaultFuel
+	}
+	if memory == 0 {
+		memory = DefaultMemory
+	}
+	pages := min(memory/pageSize, maxPages)
+	return &Engine{fuel: fuel, pages: uint32(pages), blockNumber: big.NewInt(0)}
+}
+
+func (e *Engine) SetConfig(_blockNumber *big.Int, _blockTime uint64, _programAddress common.Address, _callerAddress common.Address) {
+	e.blockNumber = _blockNumber
+	e.blockTime = _blockTime
+	e.programAddress = _programAddress
+	e.callerAddress = _callerAddress
+}
+
+// FuelUsed returns the fuel consumed by the last deploy or execution, it is reported as its gas
+func (e *Engine) FuelUsed() uint64 {
+	return e.fuel - e.left
+}
+
+// Deploy instantiates the program, calls deploy when exported and returns the initial states
+func (e *Engine) Deploy(code []byte) ([]byte, error) {
+	ctx := context.Background()
+	runtime, mod, err := e.instantiate(ctx, code)
+	if err != nil {
+		return nil, err
+	}
+	defer runtime.Close(ctx)
+
+	if deploy := mod.ExportedFunction("deploy"); deploy != nil {
+		_, err = e.call(ctx, deploy)
+		if err != nil {
+			return nil, fmt.Errorf("failed to call deploy: %v", err)
+		}
+	}
+	return e.getStates(ctx, mod)
+}
+
+// Execute restores the states, runs the input and returns the new states and the result
+func (e *Engine) Execute(code []byte, states []byte, input []byte) ([]byte, []byte, error) {
+	ctx := context.Background()
+	runtime, mod, err := e.instantiate(ctx, code)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer runtime.Close(ctx)
+
+	// restore the states
+	ptr, err := e.write(ctx, mod, states)
+	if err != nil {
+		return nil, nil, err
+	}
+	_, err = e.call(ctx, mod.ExportedFunction("set_states"), uint64(ptr), uint64(len(states)))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to call set_states: %v", err)
+	}
+
+	// execute
+	ptr, err = e.write(ctx, mod, input)
+	if err != nil {
+		return nil, nil, err
+	}
+	res, err := e.call(ctx, mod.ExportedFunction("execute"), uint64(ptr), uint64(len(input)))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to call execute: %v", err)
+	}
+	result, err := read(mod, res[0])
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read result: %v", err)
+	}
+
+	newStates, err := e.getStates(ctx, mod)
+	if err != nil {
+		return nil, nil, err
+	}
+	return newStates, result, nil
+}
+
+// create a runtime with the host functions and instantiate the metered program
+func (e *Engine) instantiate(ctx context.Context, code []byte) (wazero.Runtime, api.Module, error) {
+	e.left = e.fuel
+	metered, err := meter(code)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to meter program: %v", err)
+	}
+
+	// the interpreter does not need executable memory, which is not available in every enclave
+	config := wazero.NewRuntimeConfigInterpreter().WithMemoryLimitPages(e.pages)
+	runtime := wazero.NewRuntimeWithConfig(ctx, config)
+
+	_, err = runtime.NewHostModuleBuilder(hostModule).
+		NewFunctionBuilder().WithFunc(e.caller).Export("caller").
+		NewFunctionBuilder().WithFunc(e.program).Export("program_address").
+		NewFunctionBuilder().WithFunc(e.number).Export("block_number").
+		NewFunctionBuilder().WithFunc(e.time).Export("block_time").
+		NewFunctionBuilder().WithFunc(e.fuelLeft).Export("fuel_left").
+		Instantiate(ctx)
+	if err != nil {
+		runtime.Close(ctx)
+		return nil, nil, fmt.Errorf("failed to instantiate host module: %v", err)
+	}
+
+	compiled, err := runtime.CompileModule(ctx, metered)
+	if err != nil {
+		runtime.Close(ctx)
+		return nil, nil, fmt.Errorf("failed to compile program: %v", err)
+	}
+	for _, name := range []string{"alloc", "get_states", "set_states", "execute"} {
+		if _, ok := compiled.ExportedFunctions()[name]; !ok {
+			runtime.Close(ctx)
+			return nil, nil, fmt.Errorf("program does not export %s", name)
+		}
+	}
+	if _, ok := compiled.ExportedMemories()[memoryExport]; !ok {
+		runtime.Close(ctx)
+		return nil, nil, fmt.Errorf("program does not export %s", memoryExport)
+	}
+
+	// do not run _start, programs are libraries
+	mod, err := e.instantiateModule(ctx, runtime, compiled)
+	if err != nil {
+		runtime.Close(ctx)
+		return nil, nil, err
+	}
+	e.global = mod.ExportedGlobal(fuelExport).(api.MutableGlobal)
+	e.global.Set(e.left)
+	return runtime, mod, nil
+}
+
+func (e *Engine) instantiateModule(ctx context.Context, runtime wazero.Runtime, compiled wazero.CompiledModule) (mod api.Module, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("failed to instantiate program: %v", r)
+		}
+	}()
+	mod, err = runtime.InstantiateModule(ctx, compiled, wazero.NewModuleConfig().WithStartFunctions())
+	if err != nil {
+		return nil, fmt.Errorf("failed to instantiate program: %v", err)
+	}
+	return mod, nil
+}
+
+// call a function of the program, running out of fuel is reported as ErrOutOfFuel
+func (e *Engine) call(ctx context.Context, fn api.Function, params ...uint64) (res []uint64, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+		// the fuel global holds outOfFuel once a run or a host function found too little fuel
+		if left := e.global.Get(); left == outOfFuel {
+			e.left = 0
+			res, err = nil, ErrOutOfFuel
+		} else {
+			e.left = left
+		}
+	}()
+	return fn.Call(ctx, params...)
+}
+
+func (e *Engine) getStates(ctx context.Context, mod api.Module) ([]byte, error) {
+	res, err := e.call(ctx, mod.ExportedFunction("get_states"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to call get_states: %v", err)
+	}
+	states, err := read(mod, res[0])
+	if err != nil {
+		return nil, fmt.Errorf("failed to read states: %v", err)
+	}
+	return states, nil
+}
+
+// copy data into a buffer allocated by the program
+func (e *Engine) write(ctx context.Context, mod api.Module, data []byte) (uint32, error) {
+	res, err := e.call(ctx, mod.ExportedFunction("alloc"), uint64(len(data)))
+	if err != nil {
+		return 0, fmt.Errorf("failed to call alloc: %v", err)
+	}
+	ptr := uint32(res[0])
+	if !mod.ExportedMemory(memoryExport).Write(ptr, data) {
+		return 0, fmt.Errorf("alloc returned an out of range buffer")
+	}
+	return ptr, nil
+}
+
+// read a buffer packed as ptr<<32 | len, the data is copied out of the program memory
+func read(mod api.Module, packed uint64) ([]byte, error) {
+	ptr, size := uint32(packed>>32), uint32(packed)
+	data, ok := mod.ExportedMemory(memoryExport).Read(ptr, size)
+	if !ok {
+		return nil, fmt.Errorf("buffer out of range")
+	}
+	return append([]byte{}, data...), nil
+}
+
+// consume fuel in a host function
+func (e *Engine) consume(fuel uint64) {
+	left := e.global.Get()
+	if left < fuel {
+		e.global.Set(outOfFuel)
+		panic(ErrOutOfFuel)
+	}
+	e.global.Set(left - fuel)
+}
+
+// host functions
+func (e *Engine) caller(ctx context.Context, mod api.Module, ptr uint32) {
+	e.consume(hostCost)
+	if !mod.ExportedMemory(memoryExport).Write(ptr, e.callerAddress.Bytes()) {
+		panic("caller: buffer out of range")
+	}
+}
+
+func (e *Engine) program(ctx context.Context, mod api.Module, ptr uint32) {
+	e.consume(hostCost)
+	if !mod.ExportedMemory(memoryExport).Write(ptr, e.programAddress.Bytes()) {
+		panic("program_address: buffer out of range")
+	}
+}
+
+func (e *Engine) number(ctx context.Context) uint64 {
+	e.consume(hostCost)
+	return e.blockNumber.Uint64()
+}
+
+func (e *Engine) time(ctx context.Context) uint64 {
+	e.consume(hostCost)
+	return e.blockTime
+}
+
+func (e *Engine) fuelLeft(ctx context.Context) uint64 {
+	e.consume(hostCost)
+	return e.global.Get()
+}
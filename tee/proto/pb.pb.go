@@ -21,11 +21,116 @@ const (
 	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
 )
 
+// virtual machine running a privacy program
+// the zero value is Golang, the VM of the programs deployed before the VM was recorded
+type VMType int32
+
+const (
+	VMType_Golang   VMType = 0
+	VMType_Solidity VMType = 1
+	VMType_Wasm     VMType = 2
+)
+
+// Enum value maps for VMType.
+var (
+	VMType_name = map[int32]string{
+		0: "Golang",
+		1: "Solidity",
+		2: "Wasm",
+	}
+	VMType_value = map[string]int32{
+		"Golang":   0,
+		"Solidity": 1,
+		"Wasm":     2,
+	}
+)
+
+func (x VMType) Enum() *VMType {
+	p := new(VMType)
+	*p = x
+	return p
+}
+
+func (x VMType) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (VMType) Descriptor() protoreflect.EnumDescriptor {
+	return file_pb_proto_enumTypes[0].Descriptor()
+}
+
+func (VMType) Type() protoreflect.EnumType {
+	return &file_pb_proto_enumTypes[0]
+}
+
+func (x VMType) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use VMType.Descriptor instead.
+func (VMType) EnumDescriptor() ([]byte, []int) {
+	return file_pb_proto_rawDescGZIP(), []int{0}
+}
+
+// ACL change requested by the deployer through changeACL
+type ACLOperation int32
+
+const (
+	ACLOperation_Add     ACLOperation = 0
+	ACLOperation_Remove  ACLOperation = 1
+	ACLOperation_Replace ACLOperation = 2
+)
+
+// Enum value maps for ACLOperation.
+var (
+	ACLOperation_name = map[int32]string{
+		0: "Add",
+		1: "Remove",
+		2: "Replace",
+	}
+	ACLOperation_value = map[string]int32{
+		"Add":     0,
+		"Remove":  1,
+		"Replace": 2,
+	}
+)
+
+func (x ACLOperation) Enum() *ACLOperation {
+	p := new(ACLOperation)
+	*p = x
+	return p
+}
+
+func (x ACLOperation) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (ACLOperation) Descriptor() protoreflect.EnumDescriptor {
+	return file_pb_proto_enumTypes[1].Descriptor()
+}
+
+func (ACLOperation) Type() protoreflect.EnumType {
+	return &file_pb_proto_enumTypes[1]
+}
+
+func (x ACLOperation) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use ACLOperation.Descriptor instead.
+func (ACLOperation) EnumDescriptor() ([]byte, []int) {
+	return file_pb_proto_rawDescGZIP(), []int{1}
+}
+
 type UserConfig struct {
 	state             protoimpl.MessageState `protogen:"open.v1"`
 	HistoryKeyDiscard bool                   `protobuf:"varint,1,opt,name=HistoryKeyDiscard,proto3" json:"HistoryKeyDiscard,omitempty"`
 	KeyRotation       uint32                 `protobuf:"varint,2,opt,name=KeyRotation,proto3" json:"KeyRotation,omitempty"`
 	ACL               []string               `protobuf:"bytes,3,rep,name=ACL,proto3" json:"ACL,omitempty"`
+	VM                VMType                 `protobuf:"varint,4,opt,name=VM,proto3,enum=pb.VMType" json:"VM,omitempty"`
+	TimeoutMs         uint32                 `protobuf:"varint,5,opt,name=TimeoutMs,proto3" json:"TimeoutMs,omitempty"` // execution time limit of the Go program, 0 for the TEE default
+	MemoryMB          uint32                 `protobuf:"varint,6,opt,name=MemoryMB,proto3" json:"MemoryMB,omitempty"`   // memory limit of the Go program, 0 for the TEE default
+	GasLimit          uint64                 `protobuf:"varint,7,opt,name=GasLimit,proto3" json:"GasLimit,omitempty"`   // gas of each execution of the EVM, 0 for the TEE default
 	unknownFields     protoimpl.UnknownFields
 	sizeCache         protoimpl.SizeCache
 }
@@ -81,6 +186,34 @@ func (x *UserConfig) GetACL() []string {
 	return nil
 }
 
+func (x *UserConfig) GetVM() VMType {
+	if x != nil {
+		return x.VM
+	}
+	return VMType_Golang
+}
+
+func (x *UserConfig) GetTimeoutMs() uint32 {
+	if x != nil {
+		return x.TimeoutMs
+	}
+	return 0
+}
+
+func (x *UserConfig) GetMemoryMB() uint32 {
+	if x != nil {
+		return x.MemoryMB
+	}
+	return 0
+}
+
+func (x *UserConfig) GetGasLimit() uint64 {
+	if x != nil {
+		return x.GasLimit
+	}
+	return 0
+}
+
 type Info struct {
 	state             protoimpl.MessageState `protogen:"open.v1"`
 	Keys              []string               `protobuf:"bytes,1,rep,name=Keys,proto3" json:"Keys,omitempty"`
@@ -90,6 +223,11 @@ type Info struct {
 	ACL               []string               `protobuf:"bytes,5,rep,name=ACL,proto3" json:"ACL,omitempty"`
 	ExecutionCount    uint32                 `protobuf:"varint,6,opt,name=ExecutionCount,proto3" json:"ExecutionCount,omitempty"`
 	Nounce            uint32                 `protobuf:"varint,7,opt,name=Nounce,proto3" json:"Nounce,omitempty"`
+	Deployer          string                 `protobuf:"bytes,8,opt,name=Deployer,proto3" json:"Deployer,omitempty"`
+	VM                VMType                 `protobuf:"varint,9,opt,name=VM,proto3,enum=pb.VMType" json:"VM,omitempty"`
+	TimeoutMs         uint32                 `protobuf:"varint,10,opt,name=TimeoutMs,proto3" json:"TimeoutMs,omitempty"`
+	MemoryMB          uint32                 `protobuf:"varint,11,opt,name=MemoryMB,proto3" json:"MemoryMB,omitempty"`
+	GasLimit          uint64                 `protobuf:"varint,12,opt,name=GasLimit,proto3" json:"GasLimit,omitempty"`
 	unknownFields     protoimpl.UnknownFields
 	sizeCache         protoimpl.SizeCache
 }
@@ -173,17 +311,182 @@ func (x *Info) GetNounce() uint32 {
 	return 0
 }
 
+func (x *Info) GetDeployer() string {
+	if x != nil {
+		return x.Deployer
+	}
+	return ""
+}
+
+func (x *Info) GetVM() VMType {
+	if x != nil {
+		return x.VM
+	}
+	return VMType_Golang
+}
+
+func (x *Info) GetTimeoutMs() uint32 {
+	if x != nil {
+		return x.TimeoutMs
+	}
+	return 0
+}
+
+func (x *Info) GetMemoryMB() uint32 {
+	if x != nil {
+		return x.MemoryMB
+	}
+	return 0
+}
+
+func (x *Info) GetGasLimit() uint64 {
+	if x != nil {
+		return x.GasLimit
+	}
+	return 0
+}
+
+// input of an execution, encrypted with the key of the TEE
+type ExecutionInput struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Input         []byte                 `protobuf:"bytes,1,opt,name=Input,proto3" json:"Input,omitempty"`        // GolangInput of a Go program, ABI encoded call of a Solidity program
+	GasLimit      uint64                 `protobuf:"varint,2,opt,name=GasLimit,proto3" json:"GasLimit,omitempty"` // gas of this execution, 0 for the gas limit of the program
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ExecutionInput) Reset() {
+	*x = ExecutionInput{}
+	mi := &file_pb_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ExecutionInput) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ExecutionInput) ProtoMessage() {}
+
+func (x *ExecutionInput) ProtoReflect() protoreflect.Message {
+	mi := &file_pb_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ExecutionInput.ProtoReflect.Descriptor instead.
+func (*ExecutionInput) Descriptor() ([]byte, []int) {
+	return file_pb_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *ExecutionInput) GetInput() []byte {
+	if x != nil {
+		return x.Input
+	}
+	return nil
+}
+
+func (x *ExecutionInput) GetGasLimit() uint64 {
+	if x != nil {
+		return x.GasLimit
+	}
+	return 0
+}
+
+// result of an execution, encrypted with the result key
+type ExecutionResult struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Result        []byte                 `protobuf:"bytes,1,opt,name=Result,proto3" json:"Result,omitempty"`             // JSON of the result, the bytes returned by a solidity or wasm program are a base64 string
+	GasUsed       uint64                 `protobuf:"varint,2,opt,name=GasUsed,proto3" json:"GasUsed,omitempty"`          // gas used in the EVM
+	Error         string                 `protobuf:"bytes,3,opt,name=Error,proto3" json:"Error,omitempty"`               // reason of a failed execution
+	Revert        []byte                 `protobuf:"bytes,4,opt,name=Revert,proto3" json:"Revert,omitempty"`             // data of the revert of a solidity program: Error(string), Panic(uint256) or a custom error
+	RevertReason  string                 `protobuf:"bytes,5,opt,name=RevertReason,proto3" json:"RevertReason,omitempty"` // decoded Error(string) or Panic(uint256), empty for a custom error
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ExecutionResult) Reset() {
+	*x = ExecutionResult{}
+	mi := &file_pb_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ExecutionResult) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ExecutionResult) ProtoMessage() {}
+
+func (x *ExecutionResult) ProtoReflect() protoreflect.Message {
+	mi := &file_pb_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ExecutionResult.ProtoReflect.Descriptor instead.
+func (*ExecutionResult) Descriptor() ([]byte, []int) {
+	return file_pb_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *ExecutionResult) GetResult() []byte {
+	if x != nil {
+		return x.Result
+	}
+	return nil
+}
+
+func (x *ExecutionResult) GetGasUsed() uint64 {
+	if x != nil {
+		return x.GasUsed
+	}
+	return 0
+}
+
+func (x *ExecutionResult) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+func (x *ExecutionResult) GetRevert() []byte {
+	if x != nil {
+		return x.Revert
+	}
+	return nil
+}
+
+func (x *ExecutionResult) GetRevertReason() string {
+	if x != nil {
+		return x.RevertReason
+	}
+	return ""
+}
+
 type GolangInput struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	FuncName      string                 `protobuf:"bytes,1,opt,name=FuncName,proto3" json:"FuncName,omitempty"`
-	Args          []byte                 `protobuf:"bytes,2,opt,name=Args,proto3" json:"Args,omitempty"`
+	Args          []byte                 `protobuf:"bytes,2,opt,name=Args,proto3" json:"Args,omitempty"` // JSON array with one element per parameter
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
 func (x *GolangInput) Reset() {
 	*x = GolangInput{}
-	mi := &file_pb_proto_msgTypes[2]
+	mi := &file_pb_proto_msgTypes[4]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -195,7 +498,7 @@ func (x *GolangInput) String() string {
 func (*GolangInput) ProtoMessage() {}
 
 func (x *GolangInput) ProtoReflect() protoreflect.Message {
-	mi := &file_pb_proto_msgTypes[2]
+	mi := &file_pb_proto_msgTypes[4]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -208,7 +511,7 @@ func (x *GolangInput) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use GolangInput.ProtoReflect.Descriptor instead.
 func (*GolangInput) Descriptor() ([]byte, []int) {
-	return file_pb_proto_rawDescGZIP(), []int{2}
+	return file_pb_proto_rawDescGZIP(), []int{4}
 }
 
 func (x *GolangInput) GetFuncName() string {
@@ -225,36 +528,129 @@ func (x *GolangInput) GetArgs() []byte {
 	return nil
 }
 
+type ACLInput struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Op            ACLOperation           `protobuf:"varint,1,opt,name=Op,proto3,enum=pb.ACLOperation" json:"Op,omitempty"`
+	Addresses     []string               `protobuf:"bytes,2,rep,name=Addresses,proto3" json:"Addresses,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ACLInput) Reset() {
+	*x = ACLInput{}
+	mi := &file_pb_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ACLInput) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ACLInput) ProtoMessage() {}
+
+func (x *ACLInput) ProtoReflect() protoreflect.Message {
+	mi := &file_pb_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ACLInput.ProtoReflect.Descriptor instead.
+func (*ACLInput) Descriptor() ([]byte, []int) {
+	return file_pb_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *ACLInput) GetOp() ACLOperation {
+	if x != nil {
+		return x.Op
+	}
+	return ACLOperation_Add
+}
+
+func (x *ACLInput) GetAddresses() []string {
+	if x != nil {
+		return x.Addresses
+	}
+	return nil
+}
+
 var File_pb_proto protoreflect.FileDescriptor
 
 var file_pb_proto_rawDesc = string([]byte{
-	0x0a, 0x08, 0x70, 0x62, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x12, 0x02, 0x70, 0x62, 0x22, 0x6e,
-	0x0a, 0x0a, 0x55, 0x73, 0x65, 0x72, 0x43, 0x6f, 0x6e, 0x66, 0x69, 0x67, 0x12, 0x2c, 0x0a, 0x11,
-	0x48, 0x69, 0x73, 0x74, 0x6f, 0x72, 0x79, 0x4b, 0x65, 0x79, 0x44, 0x69, 0x73, 0x63, 0x61, 0x72,
-	0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x08, 0x52, 0x11, 0x48, 0x69, 0x73, 0x74, 0x6f, 0x72, 0x79,
-	0x4b, 0x65, 0x79, 0x44, 0x69, 0x73, 0x63, 0x61, 0x72, 0x64, 0x12, 0x20, 0x0a, 0x0b, 0x4b, 0x65,
-	0x79, 0x52, 0x6f, 0x74, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0d, 0x52,
-	0x0b, 0x4b, 0x65, 0x79, 0x52, 0x6f, 0x74, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x10, 0x0a, 0x03,
-	0x41, 0x43, 0x4c, 0x18, 0x03, 0x20, 0x03, 0x28, 0x09, 0x52, 0x03, 0x41, 0x43, 0x4c, 0x22, 0xd6,
-	0x01, 0x0a, 0x04, 0x49, 0x6e, 0x66, 0x6f, 0x12, 0x12, 0x0a, 0x04, 0x4b, 0x65, 0x79, 0x73, 0x18,
-	0x01, 0x20, 0x03, 0x28, 0x09, 0x52, 0x04, 0x4b, 0x65, 0x79, 0x73, 0x12, 0x18, 0x0a, 0x07, 0x43,
-	0x6f, 0x64, 0x65, 0x4b, 0x65, 0x79, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x43, 0x6f,
-	0x64, 0x65, 0x4b, 0x65, 0x79, 0x12, 0x2c, 0x0a, 0x11, 0x48, 0x69, 0x73, 0x74, 0x6f, 0x72, 0x79,
-	0x4b, 0x65, 0x79, 0x44, 0x69, 0x73, 0x63, 0x61, 0x72, 0x64, 0x18, 0x03, 0x20, 0x01, 0x28, 0x08,
-	0x52, 0x11, 0x48, 0x69, 0x73, 0x74, 0x6f, 0x72, 0x79, 0x4b, 0x65, 0x79, 0x44, 0x69, 0x73, 0x63,
-	0x61, 0x72, 0x64, 0x12, 0x20, 0x0a, 0x0b, 0x4b, 0x65, 0x79, 0x52, 0x6f, 0x74, 0x61, 0x74, 0x69,
-	0x6f, 0x6e, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x0b, 0x4b, 0x65, 0x79, 0x52, 0x6f, 0x74,
-	0x61, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x10, 0x0a, 0x03, 0x41, 0x43, 0x4c, 0x18, 0x05, 0x20, 0x03,
-	0x28, 0x09, 0x52, 0x03, 0x41, 0x43, 0x4c, 0x12, 0x26, 0x0a, 0x0e, 0x45, 0x78, 0x65, 0x63, 0x75,
-	0x74, 0x69, 0x6f, 0x6e, 0x43, 0x6f, 0x75, 0x6e, 0x74, 0x18, 0x06, 0x20, 0x01, 0x28, 0x0d, 0x52,
-	0x0e, 0x45, 0x78, 0x65, 0x63, 0x75, 0x74, 0x69, 0x6f, 0x6e, 0x43, 0x6f, 0x75, 0x6e, 0x74, 0x12,
-	0x16, 0x0a, 0x06, 0x4e, 0x6f, 0x75, 0x6e, 0x63, 0x65, 0x18, 0x07, 0x20, 0x01, 0x28, 0x0d, 0x52,
-	0x06, 0x4e, 0x6f, 0x75, 0x6e, 0x63, 0x65, 0x22, 0x3d, 0x0a, 0x0b, 0x47, 0x6f, 0x6c, 0x61, 0x6e,
-	0x67, 0x49, 0x6e, 0x70, 0x75, 0x74, 0x12, 0x1a, 0x0a, 0x08, 0x46, 0x75, 0x6e, 0x63, 0x4e, 0x61,
-	0x6d, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08, 0x46, 0x75, 0x6e, 0x63, 0x4e, 0x61,
-	0x6d, 0x65, 0x12, 0x12, 0x0a, 0x04, 0x41, 0x72, 0x67, 0x73, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0c,
-	0x52, 0x04, 0x41, 0x72, 0x67, 0x73, 0x42, 0x0c, 0x5a, 0x0a, 0x2e, 0x2f, 0x70, 0x72, 0x6f, 0x74,
-	0x6f, 0x3b, 0x70, 0x62, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+	0x0a, 0x08, 0x70, 0x62, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x12, 0x02, 0x70, 0x62, 0x22, 0xe0,
+	0x01, 0x0a, 0x0a, 0x55, 0x73, 0x65, 0x72, 0x43, 0x6f, 0x6e, 0x66, 0x69, 0x67, 0x12, 0x2c, 0x0a,
+	0x11, 0x48, 0x69, 0x73, 0x74, 0x6f, 0x72, 0x79, 0x4b, 0x65, 0x79, 0x44, 0x69, 0x73, 0x63, 0x61,
+	0x72, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x08, 0x52, 0x11, 0x48, 0x69, 0x73, 0x74, 0x6f, 0x72,
+	0x79, 0x4b, 0x65, 0x79, 0x44, 0x69, 0x73, 0x63, 0x61, 0x72, 0x64, 0x12, 0x20, 0x0a, 0x0b, 0x4b,
+	0x65, 0x79, 0x52, 0x6f, 0x74, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0d,
+	0x52, 0x0b, 0x4b, 0x65, 0x79, 0x52, 0x6f, 0x74, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x10, 0x0a,
+	0x03, 0x41, 0x43, 0x4c, 0x18, 0x03, 0x20, 0x03, 0x28, 0x09, 0x52, 0x03, 0x41, 0x43, 0x4c, 0x12,
+	0x1a, 0x0a, 0x02, 0x56, 0x4d, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x0a, 0x2e, 0x70, 0x62,
+	0x2e, 0x56, 0x4d, 0x54, 0x79, 0x70, 0x65, 0x52, 0x02, 0x56, 0x4d, 0x12, 0x1c, 0x0a, 0x09, 0x54,
+	0x69, 0x6d, 0x65, 0x6f, 0x75, 0x74, 0x4d, 0x73, 0x18, 0x05, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x09,
+	0x54, 0x69, 0x6d, 0x65, 0x6f, 0x75, 0x74, 0x4d, 0x73, 0x12, 0x1a, 0x0a, 0x08, 0x4d, 0x65, 0x6d,
+	0x6f, 0x72, 0x79, 0x4d, 0x42, 0x18, 0x06, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x08, 0x4d, 0x65, 0x6d,
+	0x6f, 0x72, 0x79, 0x4d, 0x42, 0x12, 0x1a, 0x0a, 0x08, 0x47, 0x61, 0x73, 0x4c, 0x69, 0x6d, 0x69,
+	0x74, 0x18, 0x07, 0x20, 0x01, 0x28, 0x04, 0x52, 0x08, 0x47, 0x61, 0x73, 0x4c, 0x69, 0x6d, 0x69,
+	0x74, 0x22, 0xe4, 0x02, 0x0a, 0x04, 0x49, 0x6e, 0x66, 0x6f, 0x12, 0x12, 0x0a, 0x04, 0x4b, 0x65,
+	0x79, 0x73, 0x18, 0x01, 0x20, 0x03, 0x28, 0x09, 0x52, 0x04, 0x4b, 0x65, 0x79, 0x73, 0x12, 0x18,
+	0x0a, 0x07, 0x43, 0x6f, 0x64, 0x65, 0x4b, 0x65, 0x79, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x07, 0x43, 0x6f, 0x64, 0x65, 0x4b, 0x65, 0x79, 0x12, 0x2c, 0x0a, 0x11, 0x48, 0x69, 0x73, 0x74,
+	0x6f, 0x72, 0x79, 0x4b, 0x65, 0x79, 0x44, 0x69, 0x73, 0x63, 0x61, 0x72, 0x64, 0x18, 0x03, 0x20,
+	0x01, 0x28, 0x08, 0x52, 0x11, 0x48, 0x69, 0x73, 0x74, 0x6f, 0x72, 0x79, 0x4b, 0x65, 0x79, 0x44,
+	0x69, 0x73, 0x63, 0x61, 0x72, 0x64, 0x12, 0x20, 0x0a, 0x0b, 0x4b, 0x65, 0x79, 0x52, 0x6f, 0x74,
+	0x61, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x0b, 0x4b, 0x65, 0x79,
+	0x52, 0x6f, 0x74, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x10, 0x0a, 0x03, 0x41, 0x43, 0x4c, 0x18,
+	0x05, 0x20, 0x03, 0x28, 0x09, 0x52, 0x03, 0x41, 0x43, 0x4c, 0x12, 0x26, 0x0a, 0x0e, 0x45, 0x78,
+	0x65, 0x63, 0x75, 0x74, 0x69, 0x6f, 0x6e, 0x43, 0x6f, 0x75, 0x6e, 0x74, 0x18, 0x06, 0x20, 0x01,
+	0x28, 0x0d, 0x52, 0x0e, 0x45, 0x78, 0x65, 0x63, 0x75, 0x74, 0x69, 0x6f, 0x6e, 0x43, 0x6f, 0x75,
+	0x6e, 0x74, 0x12, 0x16, 0x0a, 0x06, 0x4e, 0x6f, 0x75, 0x6e, 0x63, 0x65, 0x18, 0x07, 0x20, 0x01,
+	0x28, 0x0d, 0x52, 0x06, 0x4e, 0x6f, 0x75, 0x6e, 0x63, 0x65, 0x12, 0x1a, 0x0a, 0x08, 0x44, 0x65,
+	0x70, 0x6c, 0x6f, 0x79, 0x65, 0x72, 0x18, 0x08, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08, 0x44, 0x65,
+	0x70, 0x6c, 0x6f, 0x79, 0x65, 0x72, 0x12, 0x1a, 0x0a, 0x02, 0x56, 0x4d, 0x18, 0x09, 0x20, 0x01,
+	0x28, 0x0e, 0x32, 0x0a, 0x2e, 0x70, 0x62, 0x2e, 0x56, 0x4d, 0x54, 0x79, 0x70, 0x65, 0x52, 0x02,
+	0x56, 0x4d, 0x12, 0x1c, 0x0a, 0x09, 0x54, 0x69, 0x6d, 0x65, 0x6f, 0x75, 0x74, 0x4d, 0x73, 0x18,
+	0x0a, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x09, 0x54, 0x69, 0x6d, 0x65, 0x6f, 0x75, 0x74, 0x4d, 0x73,
+	0x12, 0x1a, 0x0a, 0x08, 0x4d, 0x65, 0x6d, 0x6f, 0x72, 0x79, 0x4d, 0x42, 0x18, 0x0b, 0x20, 0x01,
+	0x28, 0x0d, 0x52, 0x08, 0x4d, 0x65, 0x6d, 0x6f, 0x72, 0x79, 0x4d, 0x42, 0x12, 0x1a, 0x0a, 0x08,
+	0x47, 0x61, 0x73, 0x4c, 0x69, 0x6d, 0x69, 0x74, 0x18, 0x0c, 0x20, 0x01, 0x28, 0x04, 0x52, 0x08,
+	0x47, 0x61, 0x73, 0x4c, 0x69, 0x6d, 0x69, 0x74, 0x22, 0x42, 0x0a, 0x0e, 0x45, 0x78, 0x65, 0x63,
+	0x75, 0x74, 0x69, 0x6f, 0x6e, 0x49, 0x6e, 0x70, 0x75, 0x74, 0x12, 0x14, 0x0a, 0x05, 0x49, 0x6e,
+	0x70, 0x75, 0x74, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x05, 0x49, 0x6e, 0x70, 0x75, 0x74,
+	0x12, 0x1a, 0x0a, 0x08, 0x47, 0x61, 0x73, 0x4c, 0x69, 0x6d, 0x69, 0x74, 0x18, 0x02, 0x20, 0x01,
+	0x28, 0x04, 0x52, 0x08, 0x47, 0x61, 0x73, 0x4c, 0x69, 0x6d, 0x69, 0x74, 0x22, 0x95, 0x01, 0x0a,
+	0x0f, 0x45, 0x78, 0x65, 0x63, 0x75, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x65, 0x73, 0x75, 0x6c, 0x74,
+	0x12, 0x16, 0x0a, 0x06, 0x52, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0c,
+	0x52, 0x06, 0x52, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x12, 0x18, 0x0a, 0x07, 0x47, 0x61, 0x73, 0x55,
+	0x73, 0x65, 0x64, 0x18, 0x02, 0x20, 0x01, 0x28, 0x04, 0x52, 0x07, 0x47, 0x61, 0x73, 0x55, 0x73,
+	0x65, 0x64, 0x12, 0x14, 0x0a, 0x05, 0x45, 0x72, 0x72, 0x6f, 0x72, 0x18, 0x03, 0x20, 0x01, 0x28,
+	0x09, 0x52, 0x05, 0x45, 0x72, 0x72, 0x6f, 0x72, 0x12, 0x16, 0x0a, 0x06, 0x52, 0x65, 0x76, 0x65,
+	0x72, 0x74, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x06, 0x52, 0x65, 0x76, 0x65, 0x72, 0x74,
+	0x12, 0x22, 0x0a, 0x0c, 0x52, 0x65, 0x76, 0x65, 0x72, 0x74, 0x52, 0x65, 0x61, 0x73, 0x6f, 0x6e,
+	0x18, 0x05, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0c, 0x52, 0x65, 0x76, 0x65, 0x72, 0x74, 0x52, 0x65,
+	0x61, 0x73, 0x6f, 0x6e, 0x22, 0x3d, 0x0a, 0x0b, 0x47, 0x6f, 0x6c, 0x61, 0x6e, 0x67, 0x49, 0x6e,
+	0x70, 0x75, 0x74, 0x12, 0x1a, 0x0a, 0x08, 0x46, 0x75, 0x6e, 0x63, 0x4e, 0x61, 0x6d, 0x65, 0x18,
+	0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08, 0x46, 0x75, 0x6e, 0x63, 0x4e, 0x61, 0x6d, 0x65, 0x12,
+	0x12, 0x0a, 0x04, 0x41, 0x72, 0x67, 0x73, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x04, 0x41,
+	0x72, 0x67, 0x73, 0x22, 0x4a, 0x0a, 0x08, 0x41, 0x43, 0x4c, 0x49, 0x6e, 0x70, 0x75, 0x74, 0x12,
+	0x20, 0x0a, 0x02, 0x4f, 0x70, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x10, 0x2e, 0x70, 0x62,
+	0x2e, 0x41, 0x43, 0x4c, 0x4f, 0x70, 0x65, 0x72, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x02, 0x4f,
+	0x70, 0x12, 0x1c, 0x0a, 0x09, 0x41, 0x64, 0x64, 0x72, 0x65, 0x73, 0x73, 0x65, 0x73, 0x18, 0x02,
+	0x20, 0x03, 0x28, 0x09, 0x52, 0x09, 0x41, 0x64, 0x64, 0x72, 0x65, 0x73, 0x73, 0x65, 0x73, 0x2a,
+	0x2c, 0x0a, 0x06, 0x56, 0x4d, 0x54, 0x79, 0x70, 0x65, 0x12, 0x0a, 0x0a, 0x06, 0x47, 0x6f, 0x6c,
+	0x61, 0x6e, 0x67, 0x10, 0x00, 0x12, 0x0c, 0x0a, 0x08, 0x53, 0x6f, 0x6c, 0x69, 0x64, 0x69, 0x74,
+	0x79, 0x10, 0x01, 0x12, 0x08, 0x0a, 0x04, 0x57, 0x61, 0x73, 0x6d, 0x10, 0x02, 0x2a, 0x30, 0x0a,
+	0x0c, 0x41, 0x43, 0x4c, 0x4f, 0x70, 0x65, 0x72, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x07, 0x0a,
+	0x03, 0x41, 0x64, 0x64, 0x10, 0x00, 0x12, 0x0a, 0x0a, 0x06, 0x52, 0x65, 0x6d, 0x6f, 0x76, 0x65,
+	0x10, 0x01, 0x12, 0x0b, 0x0a, 0x07, 0x52, 0x65, 0x70, 0x6c, 0x61, 0x63, 0x65, 0x10, 0x02, 0x42,
+	0x0c, 0x5a, 0x0a, 0x2e, 0x2f, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x3b, 0x70, 0x62, 0x62, 0x06, 0x70,
+	0x72, 0x6f, 0x74, 0x6f, 0x33,
 })
 
 var (
@@ -269,18 +665,27 @@ func file_pb_proto_rawDescGZIP() []byte {
 	return file_pb_proto_rawDescData
 }
 
-var file_pb_proto_msgTypes = make([]protoimpl.MessageInfo, 3)
+var file_pb_proto_enumTypes = make([]protoimpl.EnumInfo, 2)
+var file_pb_proto_msgTypes = make([]protoimpl.MessageInfo, 6)
 var file_pb_proto_goTypes = []any{
-	(*UserConfig)(nil),  // 0: pb.UserConfig
-	(*Info)(nil),        // 1: pb.Info
-	(*GolangInput)(nil), // 2: pb.GolangInput
+	(VMType)(0),             // 0: pb.VMType
+	(ACLOperation)(0),       // 1: pb.ACLOperation
+	(*UserConfig)(nil),      // 2: pb.UserConfig
+	(*Info)(nil),            // 3: pb.Info
+	(*ExecutionInput)(nil),  // 4: pb.ExecutionInput
+	(*ExecutionResult)(nil), // 5: pb.ExecutionResult
+	(*GolangInput)(nil),     // 6: pb.GolangInput
+	(*ACLInput)(nil),        // 7: pb.ACLInput
 }
 var file_pb_proto_depIdxs = []int32{
-	0, // [0:0] is the sub-list for method output_type
-	0, // [0:0] is the sub-list for method input_type
-	0, // [0:0] is the sub-list for extension type_name
-	0, // [0:0] is the sub-list for extension extendee
-	0, // [0:0] is the sub-list for field type_name
+	0, // 0: pb.UserConfig.VM:type_name -> pb.VMType
+	0, // 1: pb.Info.VM:type_name -> pb.VMType
+	1, // 2: pb.ACLInput.Op:type_name -> pb.ACLOperation
+	3, // [3:3] is the sub-list for method output_type
+	3, // [3:3] is the sub-list for method input_type
+	3, // [3:3] is the sub-list for extension type_name
+	3, // [3:3] is the sub-list for extension extendee
+	0, // [0:3] is the sub-list for field type_name
 }
 
 func init() { file_pb_proto_init() }
@@ -293,13 +698,14 @@ func file_pb_proto_init() {
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_pb_proto_rawDesc), len(file_pb_proto_rawDesc)),
-			NumEnums:      0,
-			NumMessages:   3,
+			NumEnums:      2,
+			NumMessages:   6,
 			NumExtensions: 0,
 			NumServices:   0,
 		},
 		GoTypes:           file_pb_proto_goTypes,
 		DependencyIndexes: file_pb_proto_depIdxs,
+		EnumInfos:         file_pb_proto_enumTypes,
 		MessageInfos:      file_pb_proto_msgTypes,
 	}.Build()
 	File_pb_proto = out.File
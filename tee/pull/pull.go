@@ -1,11 +1,11 @@
 package pull
 
 import (
+	"errors"
 	"fmt"
 	"tee/help"
 	"tee/key"
-	"tee/ocs"
-	"tee/process/cache"
+	"tee/node"
 	pb "tee/proto"
 	"tee/utils"
 
@@ -13,35 +13,39 @@ import (
 	"google.golang.org/protobuf/proto"
 )
 
-var cacheProgramInfo = make(map[common.Address]pb.Info)
+// ErrUnknownProgram is returned when no program is deployed at the address
+var ErrUnknownProgram = errors.New("unknown program")
 
-func GetProgramInfo(programAddress common.Address) (*pb.Info, error) {
+func GetProgramInfo(n *node.Node, programAddress common.Address) (*pb.Info, error) {
 	// get from cache
-	info := cache.GetProgramInfo(programAddress)
+	info := n.Cache.GetProgramInfo(programAddress)
 	if info != nil {
 		// fmt.Println("Get program info from cache")
 		return info, nil
 	}
 
-	contractAddr := common.HexToAddress(help.MCAddress)
-	parsedABI := help.ParsedMCABI
+	contractAddr := common.HexToAddress(n.Chain.MCAddress)
+	parsedABI := n.Chain.ParsedMCABI
 
 	// get program info from contract
 	var infoHashOut [32]byte
-	err := help.CallContractMethod(parsedABI, contractAddr, "ProgramList", []interface{}{programAddress}, &infoHashOut)
+	err := n.Chain.CallContractMethod(parsedABI, contractAddr, "ProgramList", []interface{}{programAddress}, &infoHashOut)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get program info: %v", err)
 	}
+	if infoHashOut == [32]byte{} {
+		return nil, ErrUnknownProgram
+	}
 	infoHash := infoHashOut[:]
 
 	// get program info from off-chain
-	encryptedInfo := ocs.GetInfo(programAddress, infoHash)
+	encryptedInfo := n.OCS.GetInfo(programAddress, infoHash)
 	if !key.MatchHash(encryptedInfo, infoHash) {
 		return nil, fmt.Errorf("info hash mismatch")
 	}
 
 	// decypt result
-	decryptedInfo, err := key.DecryptAES(encryptedInfo, key.KeyMgt)
+	decryptedInfo, err := key.DecryptAES(encryptedInfo, n.Keys.KeyMgt)
 	if err != nil {
 		return nil, fmt.Errorf("failed to decrypt program information: %v", err)
 	}
@@ -53,14 +57,12 @@ func GetProgramInfo(programAddress common.Address) (*pb.Info, error) {
 		return nil, fmt.Errorf("failed to decode program information: %v", err)
 	}
 
-	// save to cache
-	cacheProgramInfo[programAddress] = programInfo
 	return &programInfo, nil
 }
 
-func GetProgramDetails(programAddress common.Address, stateKey string, codeKey string) ([]byte, []byte, error) {
+func GetProgramDetails(n *node.Node, programAddress common.Address, stateKey string, codeKey string) ([]byte, []byte, error) {
 	// get from cache
-	code, states := cache.GetProgramDetails(programAddress)
+	code, states := n.Cache.GetProgramDetails(programAddress)
 	if code != nil && states != nil {
 		// fmt.Println("Get program details from cache")
 		return code, states, nil
@@ -68,7 +70,7 @@ func GetProgramDetails(programAddress common.Address, stateKey string, codeKey s
 
 	// compatibal without statekey and codekey
 	if stateKey == "" && codeKey == "" {
-		info, err := GetProgramInfo(programAddress)
+		info, err := GetProgramInfo(n, programAddress)
 		if err != nil {
 			return nil, nil, fmt.Errorf("failed to get program info: %v", err)
 		}
@@ -77,19 +79,19 @@ func GetProgramDetails(programAddress common.Address, stateKey string, codeKey s
 		codeKey = info.CodeKey
 	}
 
-	parsedABI := help.ParsedMCABI
-	MCAddress := common.HexToAddress(help.MCAddress)
+	parsedABI := n.Chain.ParsedMCABI
+	MCAddress := common.HexToAddress(n.Chain.MCAddress)
 
 	// get code hash from contract
 	var codeHashOut [32]byte
-	err := help.CallContractMethod(parsedABI, MCAddress, "ProgramCodes", []interface{}{programAddress}, &codeHashOut)
+	err := n.Chain.CallContractMethod(parsedABI, MCAddress, "ProgramCodes", []interface{}{programAddress}, &codeHashOut)
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to get code: %v", err)
 	}
 	codeHash := codeHashOut[:]
 
 	// get code from off-chain
-	encryptedCode := ocs.GetCode(programAddress)
+	encryptedCode := n.OCS.GetCode(programAddress)
 	if !key.MatchHash(encryptedCode, codeHash) {
 		return nil, nil, fmt.Errorf("code hash mismatch")
 	}
@@ -102,14 +104,14 @@ func GetProgramDetails(programAddress common.Address, stateKey string, codeKey s
 
 	// get states hash from contract
 	var statesHashOut [32]byte
-	err = help.CallContractMethod(parsedABI, MCAddress, "ProgramStates", []interface{}{programAddress}, &statesHashOut)
+	err = n.Chain.CallContractMethod(parsedABI, MCAddress, "ProgramStates", []interface{}{programAddress}, &statesHashOut)
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to get states: %v", err)
 	}
 	statesHash := statesHashOut[:]
 
 	// get states from off-chain
-	encryptedStates := ocs.GetStates(programAddress, statesHash)
+	encryptedStates := n.OCS.GetStates(programAddress, statesHash)
 	if !key.MatchHash(encryptedStates, statesHash) {
 		return nil, nil, fmt.Errorf("states hash mismatch")
 	}
@@ -123,13 +125,13 @@ func GetProgramDetails(programAddress common.Address, stateKey string, codeKey s
 	return code, states, nil
 }
 
-func GetLatestExecutionBlock() (*utils.BlockInfo, error) {
-	parsedABI := help.ParsedMCABI
+func GetLatestExecutionBlock(chain *help.Chain) (*utils.BlockInfo, error) {
+	parsedABI := chain.ParsedMCABI
 	methodName := "latestExecutionBlock"
 
 	// get latest execution block from contract
 	var block utils.BlockInfo
-	err := help.CallContractMethod(parsedABI, common.HexToAddress(help.MCAddress), methodName, nil, &block)
+	err := chain.CallContractMethod(parsedABI, common.HexToAddress(chain.MCAddress), methodName, nil, &block)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get latest execution block: %v", err)
 	}
@@ -0,0 +1,103 @@
+// Detect chain reorganizations that orphan blocks the TEE has already executed.
+package reorg
+
+import (
+	"fmt"
+	"tee/help"
+	"tee/ocs"
+	"tee/process/cache"
+	"tee/utils"
+)
+
+// a range of blocks executed by this TEE, not yet final
+type executedRange struct {
+	Start    uint64
+	End      uint64
+	Hashes   map[uint64][32]byte // hashes of the blocks the outputs are based on
+	Snapshot int                 // off-chain storage snapshot before the range was executed
+}
+
+// blocks deeper than this below the head are considered final
+const finalityDepth = 64
+
+// Tracker remembers the executed ranges of one TEE
+type Tracker struct {
+	chain  *help.Chain
+	store  *ocs.Store
+	cache  *cache.Cache
+	ranges []executedRange
+}
+
+func New(chain *help.Chain, store *ocs.Store, cache *cache.Cache) *Tracker {
+	return &Tracker{
+		chain:  chain,
+		store:  store,
+		cache:  cache,
+		ranges: []executedRange{},
+	}
+}
+
+// Record remembers the block hashes of an executed range and the off-chain storage snapshot taken before it
+func (t *Tracker) Record(start uint64, end uint64, hashes map[uint64][32]byte, snapshot int) {
+	t.ranges = append(t.ranges, executedRange{
+		Start:    start,
+		End:      end,
+		Hashes:   hashes,
+		Snapshot: snapshot,
+	})
+}
+
+// Drop forgets the ranges executed after the given snapshot, e.g. those of a submission lost to a rival,
+// their writes are discarded by the caller
+func (t *Tracker) Drop(snapshot int) {
+	i := 0
+	for i < len(t.ranges) && t.ranges[i].Snapshot < snapshot {
+		i++
+	}
+	t.ranges = t.ranges[:i]
+}
+
+// Check compares the stored block hashes with the current chain.
+// When a reorg is detected, all writes of the orphaned ranges are discarded, so that they are re-executed.
+func (t *Tracker) Check() (bool, error) {
+	for i, r := range t.ranges {
+		for num, hash := range r.Hashes {
+			block, err := utils.GetBlock(t.chain.Client, num)
+			if err != nil {
+				return false, fmt.Errorf("failed to get block %v: %v", num, err)
+			}
+			if block.BlockHash == hash {
+				continue
+			}
+
+			// orphaned: discard this range and every later range
+			fmt.Printf("Reorg detected at block %v, discarding blocks %v to %v\n", num, r.Start, t.ranges[len(t.ranges)-1].End)
+			t.store.RevertToSnapshot(r.Snapshot)
+			t.cache.ClearCache()
+			t.ranges = t.ranges[:i]
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Prune forgets the ranges that are final at the given head, they can no longer be reorged
+func (t *Tracker) Prune(head uint64) {
+	if head < finalityDepth {
+		return
+	}
+	finalized := head - finalityDepth
+	i := 0
+	for i < len(t.ranges) && t.ranges[i].End <= finalized {
+		i++
+	}
+	if i == 0 {
+		return
+	}
+	if i < len(t.ranges) {
+		t.store.Finalize(t.ranges[i].Snapshot)
+	} else {
+		t.store.Finalize(t.store.Snapshot())
+	}
+	t.ranges = t.ranges[i:]
+}
@@ -0,0 +1,271 @@
+// Package runner follows the chain and executes the events for a TEE node.
+package runner
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/big"
+	"math/rand"
+	"slices"
+	"tee/events"
+	"tee/help"
+	"tee/key"
+	"tee/node"
+	"tee/operation"
+	"tee/process"
+	"tee/pull"
+	"tee/submission"
+	"tee/utils"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// Register registers the TEE of the node on chain
+func Register(n *node.Node) error {
+	// Register the TEE on chain
+	teePK := key.FormatECDSAPublicKey(n.Keys.PublicKey)
+	// teePkHash := sha256.Sum256(teePK)
+	// localQuote := quote.GetQuote(teePkHash[:])
+	// generate a fake quote
+	localQuote := make([]byte, 0)
+	_, err := rand.Read(localQuote)
+	if err != nil {
+		return err
+	}
+	return operation.CallRegister(n.Chain, localQuote, teePK, big.NewInt(1000000000000000000), n.Account)
+}
+
+// backoff between two reconnection attempts, or between two attempts to process blocks that failed
+const minBackoff = time.Second
+const maxBackoff = time.Minute
+
+// a connection lasting healthyPeriod resets the backoff of the reconnections
+const healthyPeriod = maxBackoff
+
+// Run executes the events of every new block, it never returns
+func Run(n *node.Node) {
+	// keep following the chain, reconnect when the subscription or the RPC fails
+	backoff := minBackoff
+	for {
+		connected := time.Now()
+		err := follow(n)
+		if time.Since(connected) >= healthyPeriod {
+			backoff = minBackoff
+		}
+		log.Printf("Connection lost: %v, reconnecting in %v", err, backoff)
+		time.Sleep(backoff)
+		backoff = min(backoff*2, maxBackoff)
+
+		err = n.Chain.Reconnect()
+		if err != nil {
+			log.Printf("Failed to reconnect: %v", err)
+		}
+	}
+}
+
+// follow runs every new block until the connection fails, execution always resumes from the on-chain execution block.
+// a block that fails to process does not drop the connection, the blocks are processed again with a backoff.
+func follow(n *node.Node) error {
+	client := n.Chain.Client
+
+	// Create a channel to receive new block headers
+	headers := make(chan *types.Header)
+	sub, err := client.SubscribeNewHead(context.Background(), headers)
+	if err != nil {
+		return fmt.Errorf("failed to subscribe to new head: %v", err)
+	}
+	defer sub.Unsubscribe()
+
+	// Get the latest block number to catch up with the blocks missed while disconnected
+	blockNumber, err := client.BlockNumber(context.Background())
+	if err != nil {
+		return fmt.Errorf("failed to get the latest block number: %v", err)
+	}
+	backoff := minBackoff
+	var retry time.Time
+	step := func(head uint64) {
+		// the blocks skipped are processed with the next head
+		if time.Now().Before(retry) {
+			return
+		}
+		err := running(n, head)
+		if err != nil {
+			log.Printf("Failed to process block %v: %v, retrying in %v", head, err, backoff)
+			retry = time.Now().Add(backoff)
+			backoff = min(backoff*2, maxBackoff)
+			return
+		}
+		backoff = minBackoff
+		retry = time.Time{}
+	}
+	step(blockNumber)
+
+	// Process each new block as it arrives
+	for {
+		select {
+		case err := <-sub.Err():
+			return fmt.Errorf("error while subscribing to new head: %v", err)
+		case header := <-headers:
+			step(header.Number.Uint64())
+		}
+	}
+}
+
+func running(n *node.Node, head uint64) error {
+	// discard the executions of orphaned blocks, they are re-executed from the on-chain execution block
+	reorged, err := n.Reorg.Check()
+	if err != nil {
+		return err
+	}
+	if reorged {
+		// the pending output is based on orphaned blocks and would revert
+		n.Submission.Abort(head)
+	}
+	n.Reorg.Prune(head)
+
+	// follow the receipts of all sent transactions
+	err = n.Txs.CheckAll(head)
+	if err != nil {
+		return err
+	}
+
+	// only execute events with enough confirmations
+	if head < n.Confirmations {
+		return nil
+	}
+	end := head - n.Confirmations
+
+	startBlock, err := pull.GetLatestExecutionBlock(n.Chain)
+	if err != nil {
+		return err
+	}
+
+	// wait for our previous output before executing the next range
+	sub, status, err := n.Submission.Watch(*startBlock, head)
+	if err != nil {
+		return err
+	}
+	switch status {
+	case submission.Pending:
+		return nil
+	case submission.Lost:
+		// the winner's blocks are checked by the next submission
+		n.Reorg.Drop(sub.Snapshot)
+	}
+	err = rebase(n)
+	if err != nil {
+		return err
+	}
+
+	// retrieve all events from the last execution block to the current block
+	latest := (*startBlock).BlockNumber
+	start := latest + 1
+	if start > end {
+		return nil
+	}
+	fmt.Printf("Start: %v, End: %v\n", start, end)
+	eventsList, err := events.GetEventsFrom(n.Chain, start, end)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("evetnsLength: %v\n", len(eventsList))
+	// if there are no events, return
+	if len(eventsList) == 0 {
+		return nil
+	}
+
+	// remember the blocks the outputs are based on
+	hashes, err := blockHashes(n.Chain, startBlock, end, eventsList)
+	if err != nil {
+		return err
+	}
+
+	// process all events
+	snapshot := n.OCS.Snapshot()
+	outputs, err := process.Process(n, eventsList)
+	if err != nil {
+		n.OCS.RevertToSnapshot(snapshot)
+		return err
+	}
+
+	// another TEE may have advanced the execution block while we were executing
+	currentBlock, err := pull.GetLatestExecutionBlock(n.Chain)
+	if err != nil {
+		n.OCS.RevertToSnapshot(snapshot)
+		return err
+	}
+	if *currentBlock != *startBlock {
+		fmt.Printf("Execution block moved to %v, discarding outputs for %v to %v\n", currentBlock.BlockNumber, start, end)
+		n.OCS.RevertToSnapshot(snapshot)
+		return nil
+	}
+
+	tx, err := process.SendOutputsToChain(n, outputs, latest, end)
+	if err != nil {
+		// nothing was submitted, the range is executed again after reconnecting
+		n.OCS.RevertToSnapshot(snapshot)
+		return err
+	}
+	n.Submission.Submit(&submission.Submission{
+		Tx:       tx,
+		Start:    latest,
+		End:      end,
+		Snapshot: snapshot,
+		Programs: programs(outputs),
+	})
+	// the blocks are checked for reorgs from now on, also while the submission is pending
+	n.Reorg.Record(latest+1, end, hashes, snapshot)
+	return nil
+}
+
+// rebase pulls the winner's outputs of the programs of our lost submissions, the next range is executed on top of them
+func rebase(n *node.Node) error {
+	stale := n.Submission.Stale()
+	for _, addr := range stale {
+		_, err := pull.GetProgramInfo(n, addr)
+		if err != nil {
+			return fmt.Errorf("failed to pull the info of %v from the winner: %v", addr.Hex(), err)
+		}
+		_, _, err = pull.GetProgramDetails(n, addr, "", "")
+		if err != nil {
+			return fmt.Errorf("failed to pull the states of %v from the winner: %v", addr.Hex(), err)
+		}
+	}
+	if len(stale) > 0 {
+		fmt.Printf("Pulled the outputs of %v programs from the winner\n", len(stale))
+		n.Submission.Pulled()
+	}
+	return nil
+}
+
+// programs updated by the outputs
+func programs(outputs []help.Output) []common.Address {
+	addrs := []common.Address{}
+	for _, output := range outputs {
+		if !slices.Contains(addrs, output.ProgramAddress) {
+			addrs = append(addrs, output.ProgramAddress)
+		}
+	}
+	return addrs
+}
+
+// collect the hashes of the start block, the end block and every block containing an event
+func blockHashes(chain *help.Chain, startBlock *utils.BlockInfo, end uint64, eventsList []map[string]interface{}) (map[uint64][32]byte, error) {
+	hashes := map[uint64][32]byte{}
+	if startBlock.BlockHash != [32]byte{} {
+		hashes[startBlock.BlockNumber] = startBlock.BlockHash
+	}
+	endBlock, err := utils.GetBlock(chain.Client, end)
+	if err != nil {
+		return nil, err
+	}
+	hashes[end] = endBlock.BlockHash
+	for _, event := range eventsList {
+		blockNumber := event["blockNumber"].(*big.Int).Uint64()
+		hashes[blockNumber] = common.HexToHash(event["blockHash"].(string))
+	}
+	return hashes, nil
+}
@@ -0,0 +1,127 @@
+// Track the output transaction of this TEE while other TEEs race to submit the same range.
+package submission
+
+import (
+	"fmt"
+	"slices"
+	"tee/ocs"
+	"tee/process/cache"
+	"tee/txmgr"
+	"tee/utils"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+type Status int
+
+const (
+	None    Status = iota // nothing submitted
+	Pending               // waiting for the transaction or a rival
+	Won                   // our outputs were accepted
+	Lost                  // another TEE advanced the execution block first
+)
+
+// an output transaction that is not confirmed yet
+type Submission struct {
+	Tx       *txmgr.Tracked
+	Start    uint64           // on-chain execution block the outputs are based on
+	End      uint64           // last executed block
+	Snapshot int              // off-chain storage snapshot before the range was executed
+	Programs []common.Address // programs updated by the outputs
+}
+
+// Tracker follows the pending submission of one TEE
+type Tracker struct {
+	txs     *txmgr.Manager
+	store   *ocs.Store
+	cache   *cache.Cache
+	pending *Submission
+	stale   []common.Address // programs of lost submissions whose winning outputs are not pulled yet
+}
+
+func New(txs *txmgr.Manager, store *ocs.Store, cache *cache.Cache) *Tracker {
+	return &Tracker{
+		txs:   txs,
+		store: store,
+		cache: cache,
+	}
+}
+
+// Submit remembers the output transaction until it is won or lost
+func (t *Tracker) Submit(s *Submission) {
+	t.pending = s
+}
+
+// Watch checks the pending submission against its receipt and the on-chain latest execution block.
+// When a rival wins, the pending transaction is cancelled and our writes of the range are discarded,
+// the winner's outputs of our programs must then be pulled, see Stale, before the next range is executed.
+func (t *Tracker) Watch(latest utils.BlockInfo, head uint64) (*Submission, Status, error) {
+	if t.pending == nil {
+		return nil, None, nil
+	}
+	s := t.pending
+
+	txStatus, err := t.txs.Check(s.Tx, head)
+	if err != nil {
+		return nil, None, err
+	}
+	switch txStatus {
+	case txmgr.Success:
+		t.pending = nil
+		return s, Won, nil
+	case txmgr.Reverted, txmgr.Replaced, txmgr.Cancelled:
+		t.pending = nil
+		t.Rebase(s)
+		return s, Lost, nil
+	}
+
+	// the execution block has not moved, keep waiting for our transaction
+	if latest.BlockNumber == s.Start {
+		return s, Pending, nil
+	}
+
+	// a rival won the race
+	t.pending = nil
+	fmt.Printf("Another TEE advanced the execution block to %v, aborting our output for %v to %v\n", latest.BlockNumber, s.Start+1, s.End)
+	err = t.txs.Cancel(s.Tx, head)
+	if err != nil {
+		// the transaction may have been mined or dropped meanwhile, it reverts on checkBlock anyway
+		fmt.Printf("Failed to cancel output transaction: %v\n", err)
+	}
+	t.Rebase(s)
+	return s, Lost, nil
+}
+
+// Rebase discards the cache and off-chain writes of a lost submission,
+// the programs it updated are stale until the winner's outputs are pulled
+func (t *Tracker) Rebase(s *Submission) {
+	t.store.RevertToSnapshot(s.Snapshot)
+	t.cache.ClearCache()
+	for _, addr := range s.Programs {
+		if !slices.Contains(t.stale, addr) {
+			t.stale = append(t.stale, addr)
+		}
+	}
+}
+
+// Stale returns the programs whose outputs were lost to a rival, their on-chain states are the winner's
+func (t *Tracker) Stale() []common.Address {
+	return t.stale
+}
+
+// Pulled marks the winner's outputs of the stale programs as pulled
+func (t *Tracker) Pulled() {
+	t.stale = nil
+}
+
+// Abort drops the pending submission, e.g. when its blocks were orphaned
+func (t *Tracker) Abort(head uint64) {
+	if t.pending == nil {
+		return
+	}
+	err := t.txs.Cancel(t.pending.Tx, head)
+	if err != nil {
+		fmt.Printf("Failed to cancel output transaction: %v\n", err)
+	}
+	t.pending = nil
+}
@@ -0,0 +1,364 @@
+// Send TEE transactions and follow them until they are final: track receipts,
+// replace stuck transactions with a higher fee and resync the nonce when the node rejects it.
+package txmgr
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"strings"
+	"tee/help"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+type Status int
+
+const (
+	Pending   Status = iota // not mined yet
+	Success                 // mined and succeeded
+	Reverted                // mined and reverted
+	Replaced                // the nonce was used by another transaction
+	Cancelled               // our cancellation was mined instead
+)
+
+func (s Status) String() string {
+	switch s {
+	case Pending:
+		return "pending"
+	case Success:
+		return "success"
+	case Reverted:
+		return "reverted"
+	case Replaced:
+		return "replaced"
+	case Cancelled:
+		return "cancelled"
+	}
+	return "unknown"
+}
+
+// a transaction followed until it is final, with every version sent for its nonce
+type Tracked struct {
+	Label     string
+	Nonce     uint64
+	Txs       []*types.Transaction // the original and all fee-bumped replacements
+	SentBlock uint64               // head block when the last version was sent
+	Status    Status
+	Receipt   *types.Receipt
+	cancelled bool
+}
+
+// Tx returns the latest version of the transaction
+func (t *Tracked) Tx() *types.Transaction {
+	return t.Txs[len(t.Txs)-1]
+}
+
+// replace a transaction that is not mined after this many blocks
+const stuckBlocks = 3
+
+// a replacement must pay at least 10% more, bump by 20%
+const bumpPercent = 20
+
+// retries when the node rejects the nonce
+const maxRetries = 3
+
+// Client is the part of the RPC client the manager uses, the simulated backend of the tests implements it too
+type Client interface {
+	HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error)
+	SuggestGasPrice(ctx context.Context) (*big.Int, error)
+	SuggestGasTipCap(ctx context.Context) (*big.Int, error)
+	PendingNonceAt(ctx context.Context, account common.Address) (uint64, error)
+	NonceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (uint64, error)
+	SendTransaction(ctx context.Context, tx *types.Transaction) error
+	TransactionReceipt(ctx context.Context, txHash common.Hash) (*types.Receipt, error)
+}
+
+// Manager sends the transactions of one account
+type Manager struct {
+	chain       *help.Chain
+	client      func() Client // the client of the chain is replaced when it reconnects
+	account     help.Account
+	nonce       uint64
+	nonceSynced bool
+	tracked     []*Tracked // transactions that are not final yet
+}
+
+func New(chain *help.Chain, account help.Account) *Manager {
+	return &Manager{
+		chain:   chain,
+		client:  func() Client { return chain.Client },
+		account: account,
+		tracked: []*Tracked{},
+	}
+}
+
+// resync the nonce from the pending transactions known by the node
+func (m *Manager) syncNonce() error {
+	address := common.HexToAddress(m.account.Address)
+	_nonce, err := m.client().PendingNonceAt(context.Background(), address)
+	if err != nil {
+		return fmt.Errorf("failed to get nonce: %v", err)
+	}
+	m.nonce = _nonce
+	m.nonceSynced = true
+	return nil
+}
+
+// Send signs and sends a new transaction with the next nonce
+func (m *Manager) Send(label string, to common.Address, value *big.Int, gasLimit uint64, data []byte) (*Tracked, error) {
+	ctx := context.Background()
+	client := m.client()
+
+	head, err := client.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get head: %v", err)
+	}
+	// the type of the transaction is kept by its replacements, a pool rejects a replacement of another type
+	dynamicFee := head.BaseFee != nil
+	tip, feeCap, err := m.suggestFees(head, dynamicFee)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := 0; ; i++ {
+		if !m.nonceSynced {
+			if err = m.syncNonce(); err != nil {
+				return nil, err
+			}
+		}
+		tx := m.newTransaction(dynamicFee, m.nonce, to, value, gasLimit, tip, feeCap, data)
+		signedTx, err := m.chain.SignTransaction(m.account.PrivateKey, tx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to sign transaction: %v", err)
+		}
+		err = client.SendTransaction(ctx, signedTx)
+		if err != nil && isNonceError(err) && i < maxRetries {
+			// another transaction took our nonce, resync and try again
+			fmt.Printf("Nonce %v rejected (%v), resyncing\n", m.nonce, err)
+			m.nonceSynced = false
+			continue
+		}
+		if err != nil && !isKnown(err) {
+			return nil, fmt.Errorf("failed to send transaction: %v", err)
+		}
+
+		m.nonce++
+		fmt.Printf("Transaction sent! Tx hash: %s\n", signedTx.Hash().Hex())
+		t := &Tracked{
+			Label:     label,
+			Nonce:     signedTx.Nonce(),
+			Txs:       []*types.Transaction{signedTx},
+			SentBlock: head.Number.Uint64(),
+			Status:    Pending,
+		}
+		m.tracked = append(m.tracked, t)
+		return t, nil
+	}
+}
+
+// CheckAll follows every transaction that is not final yet
+func (m *Manager) CheckAll(head uint64) error {
+	remaining := []*Tracked{}
+	for _, t := range m.tracked {
+		status, err := m.Check(t, head)
+		if err != nil {
+			return err
+		}
+		if status == Pending {
+			remaining = append(remaining, t)
+		}
+	}
+	m.tracked = remaining
+	return nil
+}
+
+// Check looks for a receipt of any version of the transaction.
+// A transaction stuck for stuckBlocks is replaced with a higher fee, a final status is reported once.
+func (m *Manager) Check(t *Tracked, head uint64) (Status, error) {
+	if t.Status != Pending {
+		return t.Status, nil
+	}
+	ctx := context.Background()
+	client := m.client()
+
+	// read the confirmed nonce first, so that a transaction mined meanwhile is not taken as replaced
+	from := common.HexToAddress(m.account.Address)
+	confirmed, err := client.NonceAt(ctx, from, nil)
+	if err != nil {
+		return Pending, fmt.Errorf("failed to get nonce: %v", err)
+	}
+
+	// any version may have been mined
+	indexing := false
+	for _, tx := range t.Txs {
+		receipt, err := client.TransactionReceipt(ctx, tx.Hash())
+		if err == ethereum.NotFound {
+			continue
+		}
+		if isIndexing(err) {
+			// the node does not know yet whether the version was mined
+			indexing = true
+			continue
+		}
+		if err != nil {
+			return Pending, fmt.Errorf("failed to get receipt: %v", err)
+		}
+		t.Receipt = receipt
+		if t.cancelled && receipt.TxHash == t.Tx().Hash() {
+			t.Status = Cancelled
+		} else if receipt.Status == types.ReceiptStatusSuccessful {
+			t.Status = Success
+		} else {
+			t.Status = Reverted
+		}
+		report(t)
+		return t.Status, nil
+	}
+
+	// the nonce was used without any of our versions being mined, unless their receipts are not indexed yet
+	if confirmed > t.Nonce && indexing {
+		return Pending, nil
+	}
+	if confirmed > t.Nonce {
+		t.Status = Replaced
+		report(t)
+		return t.Status, nil
+	}
+
+	// replace stuck transaction
+	if head >= t.SentBlock+stuckBlocks {
+		err = m.Bump(t, head, t.Tx().To(), t.Tx().Value(), t.Tx().Gas(), t.Tx().Data())
+		if err != nil {
+			fmt.Printf("Failed to replace stuck transaction %s: %v\n", t.Tx().Hash().Hex(), err)
+		}
+	}
+	return Pending, nil
+}
+
+// Bump replaces the pending transaction with a new one using the same nonce and a higher fee
+func (m *Manager) Bump(t *Tracked, head uint64, to *common.Address, value *big.Int, gasLimit uint64, data []byte) error {
+	ctx := context.Background()
+	client := m.client()
+	prev := t.Tx()
+
+	// pay at least the bumped fee of the previous version and the current suggestion
+	header, err := client.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to get head: %v", err)
+	}
+	dynamicFee := prev.Type() == types.DynamicFeeTxType
+	tip, feeCap, err := m.suggestFees(header, dynamicFee)
+	if err != nil {
+		return err
+	}
+	tip = maxBig(tip, bump(prev.GasTipCap()))
+	feeCap = maxBig(feeCap, bump(prev.GasFeeCap()))
+
+	tx := m.newTransaction(dynamicFee, t.Nonce, *to, value, gasLimit, tip, feeCap, data)
+	signedTx, err := m.chain.SignTransaction(m.account.PrivateKey, tx)
+	if err != nil {
+		return fmt.Errorf("failed to sign transaction: %v", err)
+	}
+	err = client.SendTransaction(ctx, signedTx)
+	if err != nil && isNonceError(err) {
+		// the previous version was mined or the pool holds a better one, resync for later transactions
+		m.nonceSynced = false
+		return fmt.Errorf("replacement rejected: %v", err)
+	}
+	if err != nil && !isKnown(err) {
+		return fmt.Errorf("failed to send transaction: %v", err)
+	}
+
+	t.Txs = append(t.Txs, signedTx)
+	t.SentBlock = head
+	fmt.Printf("Transaction replaced! Tx hash: %s\n", signedTx.Hash().Hex())
+	return nil
+}
+
+// Cancel replaces the pending transaction with an empty transfer to ourselves
+func (m *Manager) Cancel(t *Tracked, head uint64) error {
+	if t.Status != Pending || t.cancelled {
+		return nil
+	}
+	from := common.HexToAddress(m.account.Address)
+	err := m.Bump(t, head, &from, big.NewInt(0), 21000, nil)
+	if err != nil {
+		return err
+	}
+	t.cancelled = true
+	return nil
+}
+
+func report(t *Tracked) {
+	if t.Receipt != nil {
+		fmt.Printf("%s: %v in block %v (tx %s, gas used %v)\n", t.Label, t.Status, t.Receipt.BlockNumber, t.Receipt.TxHash.Hex(), t.Receipt.GasUsed)
+		return
+	}
+	fmt.Printf("%s: %v (nonce %v)\n", t.Label, t.Status, t.Nonce)
+}
+
+// EIP-1559 fees for a dynamic fee transaction, otherwise a legacy gas price in both fields
+func (m *Manager) suggestFees(head *types.Header, dynamicFee bool) (*big.Int, *big.Int, error) {
+	ctx := context.Background()
+	client := m.client()
+	if !dynamicFee || head.BaseFee == nil {
+		gasPrice, err := client.SuggestGasPrice(ctx)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to suggest gas price: %v", err)
+		}
+		return gasPrice, gasPrice, nil
+	}
+	tip, err := client.SuggestGasTipCap(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to suggest gas tip: %v", err)
+	}
+	// leave room for the base fee to double
+	feeCap := new(big.Int).Add(new(big.Int).Mul(head.BaseFee, big.NewInt(2)), tip)
+	return tip, feeCap, nil
+}
+
+// a dynamic fee transaction on a chain with a base fee, even when it is 0 and the tip equals the fee cap
+func (m *Manager) newTransaction(dynamicFee bool, nonce uint64, to common.Address, value *big.Int, gasLimit uint64, tip *big.Int, feeCap *big.Int, data []byte) *types.Transaction {
+	if !dynamicFee {
+		return types.NewTransaction(nonce, to, value, gasLimit, feeCap, data)
+	}
+	return types.NewTx(&types.DynamicFeeTx{
+		ChainID:   m.chain.ChainID,
+		Nonce:     nonce,
+		GasTipCap: tip,
+		GasFeeCap: feeCap,
+		Gas:       gasLimit,
+		To:        &to,
+		Value:     value,
+		Data:      data,
+	})
+}
+
+func bump(v *big.Int) *big.Int {
+	res := new(big.Int).Mul(v, big.NewInt(100+bumpPercent))
+	res.Div(res, big.NewInt(100))
+	return res.Add(res, big.NewInt(1))
+}
+
+func maxBig(a *big.Int, b *big.Int) *big.Int {
+	if a.Cmp(b) >= 0 {
+		return a
+	}
+	return b
+}
+
+func isNonceError(err error) bool {
+	msg := strings.ToLower(err.Error())
+	return strings.Contains(msg, "nonce too low") || strings.Contains(msg, "replacement transaction underpriced")
+}
+
+func isKnown(err error) bool {
+	return strings.Contains(strings.ToLower(err.Error()), "already known")
+}
+
+func isIndexing(err error) bool {
+	return err != nil && strings.Contains(strings.ToLower(err.Error()), "transaction indexing is in progress")
+}
@@ -0,0 +1,165 @@
+package txmgr
+
+import (
+	"context"
+	"math/big"
+	"tee/help"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient/simulated"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// manager of a funded account on a simulated chain, blocks are only mined on Commit
+func newTestManager(t *testing.T) (*Manager, *simulated.Backend) {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	from := crypto.PubkeyToAddress(key.PublicKey)
+	backend := simulated.NewBackend(types.GenesisAlloc{from: {Balance: big.NewInt(params.Ether)}})
+	t.Cleanup(func() { backend.Close() })
+
+	chain := &help.Chain{ChainID: params.AllDevChainProtocolChanges.ChainID}
+	account := help.Account{Address: from.Hex(), PrivateKey: common.Bytes2Hex(crypto.FromECDSA(key))}
+	m := New(chain, account)
+	client := backend.Client()
+	m.client = func() Client { return client }
+	return m, backend
+}
+
+func head(t *testing.T, m *Manager) uint64 {
+	header, err := m.client().HeaderByNumber(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("failed to get head: %v", err)
+	}
+	return header.Number.Uint64()
+}
+
+func TestNewTransactionType(t *testing.T) {
+	m := &Manager{chain: &help.Chain{ChainID: big.NewInt(1)}}
+	tests := []struct {
+		name       string
+		dynamicFee bool
+		tip        int64
+		feeCap     int64
+		want       uint8
+	}{
+		{name: "legacy", dynamicFee: false, tip: 5, feeCap: 5, want: types.LegacyTxType},
+		{name: "dynamic", dynamicFee: true, tip: 1, feeCap: 5, want: types.DynamicFeeTxType},
+		// a chain with a base fee of 0 suggests a fee cap equal to the tip
+		{name: "dynamic without base fee", dynamicFee: true, tip: 5, feeCap: 5, want: types.DynamicFeeTxType},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tx := m.newTransaction(tt.dynamicFee, 0, common.Address{}, big.NewInt(0), 21000, big.NewInt(tt.tip), big.NewInt(tt.feeCap), nil)
+			if tx.Type() != tt.want {
+				t.Errorf("transaction of type %v, want %v", tx.Type(), tt.want)
+			}
+		})
+	}
+}
+
+func TestManager(t *testing.T) {
+	to := common.HexToAddress("0x1234")
+	tests := []struct {
+		name string
+		run  func(t *testing.T, m *Manager, backend *simulated.Backend)
+	}{
+		{
+			name: "stuck transaction is bumped",
+			run: func(t *testing.T, m *Manager, backend *simulated.Backend) {
+				tracked, err := m.Send("test", to, big.NewInt(1), 21000, nil)
+				if err != nil {
+					t.Fatalf("failed to send: %v", err)
+				}
+				status, err := m.Check(tracked, tracked.SentBlock+stuckBlocks)
+				if err != nil || status != Pending {
+					t.Fatalf("check returned %v, %v, want pending", status, err)
+				}
+				if len(tracked.Txs) != 2 {
+					t.Fatalf("%d versions sent, want 2", len(tracked.Txs))
+				}
+				prev, next := tracked.Txs[0], tracked.Txs[1]
+				if next.Type() != prev.Type() || next.Nonce() != prev.Nonce() {
+					t.Errorf("replacement of type %v and nonce %v, want %v and %v", next.Type(), next.Nonce(), prev.Type(), prev.Nonce())
+				}
+				if next.GasTipCap().Cmp(bump(prev.GasTipCap())) < 0 || next.GasFeeCap().Cmp(bump(prev.GasFeeCap())) < 0 {
+					t.Errorf("replacement fees %v/%v not bumped from %v/%v", next.GasTipCap(), next.GasFeeCap(), prev.GasTipCap(), prev.GasFeeCap())
+				}
+				backend.Commit()
+				status, err = m.Check(tracked, head(t, m))
+				if err != nil || status != Success {
+					t.Fatalf("check returned %v, %v, want success", status, err)
+				}
+				if tracked.Receipt.TxHash != next.Hash() {
+					t.Errorf("mined %v, want the replacement %v", tracked.Receipt.TxHash.Hex(), next.Hash().Hex())
+				}
+			},
+		},
+		{
+			name: "nonce too low is resynced",
+			run: func(t *testing.T, m *Manager, backend *simulated.Backend) {
+				_, err := m.Send("first", to, big.NewInt(1), 21000, nil)
+				if err != nil {
+					t.Fatalf("failed to send: %v", err)
+				}
+				// another sender uses the next nonce of the account
+				tx := m.newTransaction(true, m.nonce, to, big.NewInt(1), 21000, big.NewInt(params.GWei), big.NewInt(10*params.GWei), nil)
+				signed, err := m.chain.SignTransaction(m.account.PrivateKey, tx)
+				if err != nil {
+					t.Fatalf("failed to sign: %v", err)
+				}
+				err = m.client().SendTransaction(context.Background(), signed)
+				if err != nil {
+					t.Fatalf("failed to send: %v", err)
+				}
+				backend.Commit()
+
+				tracked, err := m.Send("second", to, big.NewInt(1), 21000, nil)
+				if err != nil {
+					t.Fatalf("failed to send after the nonce was taken: %v", err)
+				}
+				if tracked.Nonce != 2 {
+					t.Errorf("sent with nonce %v, want 2", tracked.Nonce)
+				}
+				backend.Commit()
+				status, err := m.Check(tracked, head(t, m))
+				if err != nil || status != Success {
+					t.Fatalf("check returned %v, %v, want success", status, err)
+				}
+			},
+		},
+		{
+			name: "cancelled transaction",
+			run: func(t *testing.T, m *Manager, backend *simulated.Backend) {
+				tracked, err := m.Send("test", to, big.NewInt(1), 21000, nil)
+				if err != nil {
+					t.Fatalf("failed to send: %v", err)
+				}
+				err = m.Cancel(tracked, head(t, m))
+				if err != nil {
+					t.Fatalf("failed to cancel: %v", err)
+				}
+				cancel := tracked.Tx()
+				if *cancel.To() != common.HexToAddress(m.account.Address) || cancel.Value().Sign() != 0 {
+					t.Errorf("cancellation sends %v to %v, want nothing to ourselves", cancel.Value(), cancel.To().Hex())
+				}
+				backend.Commit()
+				status, err := m.Check(tracked, head(t, m))
+				if err != nil || status != Cancelled {
+					t.Fatalf("check returned %v, %v, want cancelled", status, err)
+				}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, backend := newTestManager(t)
+			tt.run(t, m, backend)
+		})
+	}
+}
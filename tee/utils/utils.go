@@ -4,7 +4,8 @@ import (
 	"context"
 	"fmt"
 	"math/big"
-	"tee/help"
+
+	"github.com/ethereum/go-ethereum/ethclient"
 )
 
 type BlockInfo struct {
@@ -13,9 +14,7 @@ type BlockInfo struct {
 }
 
 // Get the block information by block number
-func GetBlock(num uint64) (BlockInfo, error) {
-	client := help.Client
-
+func GetBlock(client *ethclient.Client, num uint64) (BlockInfo, error) {
 	blockNum := big.NewInt(0).SetUint64(num)
 	block, err := client.BlockByNumber(context.Background(), blockNum)
 	if err != nil {
@@ -39,3 +38,12 @@ func Contains(s []string, e string) bool {
 	}
 	return false
 }
+
+// Field returns a typed value of an event map, so that a malformed event fails instead of panicking
+func Field[T any](m map[string]interface{}, name string) (T, error) {
+	v, ok := m[name].(T)
+	if !ok {
+		return v, fmt.Errorf("field %s is missing or has unexpected type %T", name, m[name])
+	}
+	return v, nil
+}